@@ -0,0 +1,90 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+// Load reports the current load of the node (e.g. number of requests in-flight)
+type Load interface {
+	Load(node string) float64
+}
+
+// Loads is the snapshot of nodes load
+type Loads map[string]float64
+
+// Load of the node
+func (loads Loads) Load(node string) float64 { return loads[node] }
+
+/*
+
+LookupKeyBounded looks up the key position on the ring using consistent hashing
+with bounded loads. The shard table is the base placement, the lookup walks
+active successors of the key in ring order until a node is found whose load
+does not exceed the capacity (1+ε)·(L+1)/N, where L is the total load of
+N active nodes. It falls back to LookupKey if bounded loads are disabled.
+*/
+func (ring *Ring) LookupKeyBounded(key string, load Load) Node {
+	shard, _ := ring.address(key)
+	return ring.lookupBounded(shard, load)
+}
+
+/*
+
+LookupBounded looks up the address position on the ring using consistent
+hashing with bounded loads. See LookupKeyBounded.
+*/
+func (ring *Ring) LookupBounded(addr uint64, load Load) Node {
+	return ring.lookupBounded(ring.shardOf(addr), load)
+}
+
+func (ring *Ring) lookupBounded(shard int, load Load) Node {
+	coord := ring.hashes[shard]
+	if ring.epsilon <= 0 {
+		return coord
+	}
+
+	n, total := 0, 0.0
+	for node, m := range ring.nodes {
+		if m.state == StateActive {
+			n++
+			total += load.Load(node)
+		}
+	}
+
+	if n == 0 {
+		return coord
+	}
+
+	capacity := (1 + ring.epsilon) * (total + 1) / float64(n)
+
+	q := int(ring.q)
+	seen := make([]string, 0, 8)
+	for i := 0; i < q && len(seen) < n; i++ {
+		hash := ring.hashes[(shard+i)%q]
+		if ring.nodes[hash.node].state != StateActive || contains(seen, hash.node) {
+			continue
+		}
+
+		if load.Load(hash.node)+1 <= capacity {
+			hash.hash = coord.hash
+			return hash
+		}
+		seen = append(seen, hash.node)
+	}
+
+	return coord
+}
@@ -0,0 +1,81 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestBoundedLoad(t *testing.T) {
+	r := New(M64_Q4096_T256, WithBoundedLoad(0.25))
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	key := randKey()
+	loads := Loads{}
+	for i := 0; i < 800; i++ {
+		node := r.LookupKeyBounded(key, loads)
+		loads[node.Node()]++
+	}
+
+	it.Ok(t).If(len(loads)).Equal(8)
+	for _, load := range loads {
+		it.Ok(t).IfTrue(load <= 1.25*100+1)
+	}
+}
+
+func TestBoundedLoadCoordinator(t *testing.T) {
+	r := New(M64_Q4096_T256, WithBoundedLoad(0.25))
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	key := randKey()
+	coord := r.LookupKey(key)
+	it.Ok(t).If(r.LookupKeyBounded(key, Loads{})).Equal(coord)
+
+	primary, _ := r.SuccessorOf(2, key)
+	node := r.LookupKeyBounded(key, Loads{coord.Node(): 10})
+	it.Ok(t).
+		If(node.Node()).Equal(primary[1].Node()).
+		If(node.Hash()).Equal(coord.Hash()).
+		If(node.Member()).Equal(primary[1].Member())
+
+	r.Handoff(primary[1].Node())
+	node = r.LookupKeyBounded(key, Loads{coord.Node(): 10})
+	it.Ok(t).
+		IfFalse(node.Node() == coord.Node()).
+		IfFalse(node.Node() == primary[1].Node())
+}
+
+func TestBoundedLoadDisabled(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	key := randKey()
+	coord := r.LookupKey(key)
+	it.Ok(t).
+		If(r.LookupKeyBounded(key, Loads{coord.Node(): 1000})).Equal(coord).
+		If(r.LookupBounded(r.Address(key), Loads{coord.Node(): 1000})).Equal(coord)
+}
@@ -0,0 +1,629 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+/*
+
+Binary snapshot of the ring
+
+	magic   "ring"
+	version byte
+	m, q, t uvarint
+	hasher  string
+	options byte, zone aware placement (since version 3), hash tags
+	epsilon float64, bounded load factor (since version 5)
+	members uvarint, followed by member records
+	  node   string
+	  state  byte (flags before version 4)
+	  weight float64, little endian (since version 2)
+	  zone   string (since version 3)
+	  addr   string (since version 6)
+	  tags   uvarint, followed by key and value strings (since version 6)
+	shards  q records
+	  hash  uvarint
+	  addr  uvarint
+	  rank  varint
+	  node  uvarint, index of member + 1, 0 is unallocated shard
+
+string is encoded as uvarint length followed by bytes
+*/
+const (
+	codecMagic   = "ring"
+	codecVersion = 6
+)
+
+// flags of member, replaced by state since version 4
+const (
+	flagHandoff = 1 << iota
+)
+
+const (
+	optionZoned = 1 << iota
+	optionHashTag
+)
+
+// Errors of binary codec
+var (
+	ErrCodecMagic   = errors.New("ring: invalid binary snapshot")
+	ErrCodecVersion = errors.New("ring: unsupported version of binary snapshot")
+	ErrCodecCorrupt = errors.New("ring: corrupted binary snapshot")
+)
+
+// sorted list of members, it makes snapshot deterministic
+func (ring *Ring) sortedMembers() []string {
+	seq := ring.Members()
+	sort.Strings(seq)
+	return seq
+}
+
+func sortedKeys(tags map[string]string) []string {
+	seq := make([]string, 0, len(tags))
+	for k := range tags {
+		seq = append(seq, k)
+	}
+	sort.Strings(seq)
+	return seq
+}
+
+/*
+
+MarshalBinary encodes the ring into binary snapshot, which carries
+the configuration, members and entire allocation of shards.
+*/
+func (ring *Ring) MarshalBinary() ([]byte, error) {
+	name := hashName(ring.hasher)
+	if name == "" {
+		return nil, fmt.Errorf("ring: hashing algorithm is not registered, use RegisterHash")
+	}
+
+	members := ring.sortedMembers()
+	index := make(map[string]uint64, len(members))
+	for i, node := range members {
+		index[node] = uint64(i + 1)
+	}
+
+	buf := make([]byte, 0, 32+len(members)*24+len(ring.hashes)*24)
+	buf = append(buf, codecMagic...)
+	buf = append(buf, codecVersion)
+	buf = appendUvarint(buf, ring.m)
+	buf = appendUvarint(buf, ring.q)
+	buf = appendUvarint(buf, ring.t)
+	buf = appendString(buf, name)
+
+	options := byte(0)
+	if ring.zoned {
+		options |= optionZoned
+	}
+	if ring.hashtag {
+		options |= optionHashTag
+	}
+	buf = append(buf, options)
+	buf = appendFloat64(buf, ring.epsilon)
+
+	buf = appendUvarint(buf, uint64(len(members)))
+	for _, node := range members {
+		m := ring.nodes[node]
+		buf = appendString(buf, node)
+		buf = append(buf, byte(m.state))
+		buf = appendFloat64(buf, m.weight)
+		buf = appendString(buf, m.zone)
+		buf = appendString(buf, m.meta.Addr)
+		buf = appendUvarint(buf, uint64(len(m.meta.Tags)))
+		for _, k := range sortedKeys(m.meta.Tags) {
+			buf = appendString(buf, k)
+			buf = appendString(buf, m.meta.Tags[k])
+		}
+	}
+
+	for _, hash := range ring.hashes {
+		id, exists := index[hash.node]
+		if !exists && hash.node != "" {
+			return nil, fmt.Errorf("ring: shard %x is allocated to unknown node %s", hash.hash, hash.node)
+		}
+
+		buf = appendUvarint(buf, hash.hash)
+		buf = appendUvarint(buf, hash.addr)
+		buf = appendVarint(buf, int64(hash.rank))
+		buf = appendUvarint(buf, id)
+	}
+
+	return buf, nil
+}
+
+/*
+
+UnmarshalBinary restores the ring from binary snapshot.
+The ring topology is restored as-is without recomputation.
+*/
+func (ring *Ring) UnmarshalBinary(data []byte) error {
+	if len(data) < len(codecMagic)+1 || string(data[:len(codecMagic)]) != codecMagic {
+		return ErrCodecMagic
+	}
+
+	version := data[len(codecMagic)]
+	if version == 0 || version > codecVersion {
+		return ErrCodecVersion
+	}
+
+	r := reader{buf: data[len(codecMagic)+1:]}
+	m := r.uvarint()
+	q := r.uvarint()
+	t := r.uvarint()
+	name := r.string()
+	options := byte(0)
+	if version >= 3 {
+		options = r.byte()
+	}
+	epsilon := 0.0
+	if version >= 5 {
+		epsilon = r.float64()
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	hasher := hashByName(name)
+	if hasher == nil {
+		return fmt.Errorf("ring: hashing algorithm %s is not registered", name)
+	}
+
+	if validate(m, q) != nil || q > uint64(len(r.buf)) {
+		return ErrCodecCorrupt
+	}
+
+	size := r.uvarint()
+	if r.err != nil || size > uint64(len(r.buf)) {
+		return ErrCodecCorrupt
+	}
+
+	members := make([]string, size)
+	nodes := make(map[string]member, size)
+	for i := range members {
+		node := r.string()
+		flags := r.byte()
+		state := State(flags)
+		if version < 4 {
+			state = StateActive
+			if flags&flagHandoff != 0 {
+				state = StateHandoff
+			}
+		}
+		weight := 1.0
+		if version >= 2 {
+			weight = r.float64()
+		}
+		if !state.valid() || !validWeight(weight) {
+			return ErrCodecCorrupt
+		}
+		desc := Member{ID: node}
+		if version >= 3 {
+			desc.Zone = r.string()
+		}
+		if version >= 6 {
+			desc.Addr = r.string()
+			tags := r.uvarint()
+			if r.err != nil || tags > uint64(len(r.buf)) {
+				return ErrCodecCorrupt
+			}
+			if tags > 0 {
+				desc.Tags = make(map[string]string, tags)
+			}
+			for ; tags > 0; tags-- {
+				k := r.string()
+				desc.Tags[k] = r.string()
+			}
+		}
+		members[i] = node
+		nodes[node] = newMember(state, weight, desc)
+	}
+
+	hashes := make(Hashes, q)
+	for i := range hashes {
+		hash := r.uvarint()
+		addr := r.uvarint()
+		rank := r.varint()
+		id := r.uvarint()
+		if r.err != nil || id > uint64(len(members)) {
+			return ErrCodecCorrupt
+		}
+
+		node := ""
+		if id > 0 {
+			node = members[id-1]
+		}
+		hashes[i] = Hash{hash: hash, addr: addr, rank: int(rank), node: node}
+	}
+
+	if r.err != nil || len(r.buf) != 0 {
+		return ErrCodecCorrupt
+	}
+
+	ring.m = m
+	ring.q = q
+	ring.t = t
+	ring.hasher = hasher
+	ring.digests = newDigests(hasher)
+	if ring.observers == nil {
+		ring.observers = &observers{}
+	}
+	ring.zoned = options&optionZoned != 0
+	if options&optionHashTag != 0 {
+		ring.hashtag = true
+		ring.extract = nil
+	}
+	ring.epsilon = epsilon
+	ring.arc = ring.segment()
+	ring.hashes = hashes
+	ring.nodes = nodes
+	ring.claims = nil
+	ring.commit()
+
+	return nil
+}
+
+//------------------------------------------------------------------------------
+//
+// JSON codec
+//
+//------------------------------------------------------------------------------
+
+// JSON representation of the ring
+type jsonRing struct {
+	M         uint64       `json:"m"`
+	Q         uint64       `json:"q"`
+	T         uint64       `json:"t"`
+	Hash      string       `json:"hash"`
+	Placement string       `json:"placement,omitempty"`
+	Epsilon   float64      `json:"epsilon,omitempty"`
+	HashTag   bool         `json:"hashtag,omitempty"`
+	Members   []jsonMember `json:"members"`
+	Shards    Hashes       `json:"shards"`
+}
+
+// placement of replicas
+const (
+	placementNode = "node"
+	placementZone = "zone"
+)
+
+// JSON representation of ring member
+type jsonMember struct {
+	Node    string            `json:"node"`
+	State   State             `json:"state,omitempty"`
+	Handoff bool              `json:"handoff"`
+	Weight  *float64          `json:"weight,omitempty"`
+	Zone    string            `json:"zone,omitempty"`
+	Addr    string            `json:"addr,omitempty"`
+	Tags    map[string]string `json:"tags,omitempty"`
+}
+
+// JSON representation of hash, addresses are hex strings
+// so that 64-bit values are not truncated by JavaScript tools
+type jsonHash struct {
+	Hash string  `json:"hash"`
+	Addr string  `json:"addr"`
+	Rank int     `json:"rank"`
+	Node string  `json:"node"`
+	Meta *Member `json:"member,omitempty"`
+	Hint string  `json:"hint,omitempty"`
+}
+
+/*
+
+MarshalJSON encodes the ring into JSON, which carries
+the configuration, members and entire allocation of shards.
+*/
+func (ring *Ring) MarshalJSON() ([]byte, error) {
+	name := hashName(ring.hasher)
+	if name == "" {
+		return nil, fmt.Errorf("ring: hashing algorithm is not registered, use RegisterHash")
+	}
+
+	members := make([]jsonMember, 0, len(ring.nodes))
+	for _, node := range ring.sortedMembers() {
+		m := ring.nodes[node]
+		members = append(members, jsonMember{
+			Node:    node,
+			State:   m.state,
+			Handoff: m.state == StateHandoff,
+			Weight:  &m.weight,
+			Zone:    m.zone,
+			Addr:    m.meta.Addr,
+			Tags:    m.meta.Tags,
+		})
+	}
+
+	// Note: descriptors of nodes are listed by members
+	shards := make(Hashes, len(ring.hashes))
+	for i, hash := range ring.hashes {
+		hash.meta = nil
+		shards[i] = hash
+	}
+
+	placement := placementNode
+	if ring.zoned {
+		placement = placementZone
+	}
+
+	return json.Marshal(jsonRing{
+		M:         ring.m,
+		Q:         ring.q,
+		T:         ring.t,
+		Hash:      name,
+		Placement: placement,
+		Epsilon:   ring.epsilon,
+		HashTag:   ring.hashtag,
+		Members:   members,
+		Shards:    shards,
+	})
+}
+
+/*
+
+UnmarshalJSON restores the ring from JSON.
+The ring topology is restored as-is without recomputation.
+*/
+func (ring *Ring) UnmarshalJSON(data []byte) error {
+	var spec jsonRing
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+
+	hasher := hashByName(spec.Hash)
+	if hasher == nil {
+		return fmt.Errorf("ring: hashing algorithm %s is not registered", spec.Hash)
+	}
+
+	if err := validate(spec.M, spec.Q); err != nil {
+		return err
+	}
+
+	if spec.Q != uint64(len(spec.Shards)) {
+		return fmt.Errorf("ring: invalid configuration m=%d, q=%d with %d shards", spec.M, spec.Q, len(spec.Shards))
+	}
+
+	nodes := make(map[string]member, len(spec.Members))
+	for _, m := range spec.Members {
+		weight := 1.0
+		if m.Weight != nil {
+			weight = *m.Weight
+		}
+		state := m.State
+		if state == StateUnknown {
+			state = StateActive
+			if m.Handoff {
+				state = StateHandoff
+			}
+		}
+		if !state.valid() {
+			return fmt.Errorf("ring: invalid state %d of node %s", state, m.Node)
+		}
+		if !validWeight(weight) {
+			return fmt.Errorf("ring: invalid weight %v of node %s", weight, m.Node)
+		}
+		nodes[m.Node] = newMember(state, weight, Member{ID: m.Node, Zone: m.Zone, Addr: m.Addr, Tags: m.Tags})
+	}
+
+	if spec.Placement != "" && spec.Placement != placementNode && spec.Placement != placementZone {
+		return fmt.Errorf("ring: unknown placement %s", spec.Placement)
+	}
+
+	for _, hash := range spec.Shards {
+		if _, exists := nodes[hash.node]; !exists && hash.node != "" {
+			return fmt.Errorf("ring: shard %x is allocated to unknown node %s", hash.hash, hash.node)
+		}
+	}
+
+	ring.m = spec.M
+	ring.q = spec.Q
+	ring.t = spec.T
+	ring.hasher = hasher
+	ring.digests = newDigests(hasher)
+	if ring.observers == nil {
+		ring.observers = &observers{}
+	}
+	ring.zoned = spec.Placement == placementZone
+	if spec.HashTag {
+		ring.hashtag = true
+		ring.extract = nil
+	}
+	ring.epsilon = spec.Epsilon
+	ring.arc = ring.segment()
+	ring.hashes = spec.Shards
+	ring.nodes = nodes
+	ring.claims = nil
+	ring.commit()
+
+	return nil
+}
+
+// MarshalJSON encodes hash into JSON
+func (hash Hash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonHash{
+		Hash: strconv.FormatUint(hash.hash, 16),
+		Addr: strconv.FormatUint(hash.addr, 16),
+		Rank: hash.rank,
+		Node: hash.node,
+		Meta: hash.meta,
+		Hint: hash.hint,
+	})
+}
+
+// UnmarshalJSON decodes hash from JSON
+func (hash *Hash) UnmarshalJSON(data []byte) error {
+	var spec jsonHash
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+
+	h, err := strconv.ParseUint(spec.Hash, 16, 64)
+	if err != nil {
+		return fmt.Errorf("ring: invalid hash %s: %w", spec.Hash, err)
+	}
+
+	a, err := strconv.ParseUint(spec.Addr, 16, 64)
+	if err != nil {
+		return fmt.Errorf("ring: invalid address %s: %w", spec.Addr, err)
+	}
+
+	*hash = Hash{hash: h, addr: a, rank: spec.Rank, node: spec.Node, meta: spec.Meta, hint: spec.Hint}
+	return nil
+}
+
+// MarshalJSON encodes primary nodes into JSON, empty list is an array
+func (primary Primary) MarshalJSON() ([]byte, error) {
+	if primary == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Hash(primary))
+}
+
+// UnmarshalJSON decodes primary nodes from JSON
+func (primary *Primary) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*[]Hash)(primary))
+}
+
+// MarshalJSON encodes handoff nodes into JSON, empty list is an array
+func (handoff Handoff) MarshalJSON() ([]byte, error) {
+	if handoff == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Hash(handoff))
+}
+
+// UnmarshalJSON decodes handoff nodes from JSON
+func (handoff *Handoff) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*[]Hash)(handoff))
+}
+
+//------------------------------------------------------------------------------
+//
+// binary primitives
+//
+//------------------------------------------------------------------------------
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], x)
+	return append(buf, b[:n]...)
+}
+
+func appendVarint(buf []byte, x int64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], x)
+	return append(buf, b[:n]...)
+}
+
+func appendFloat64(buf []byte, x float64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(x))
+	return append(buf, b[:]...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// reader of binary snapshot, it remembers the first error
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	x, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = ErrCodecCorrupt
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return x
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	x, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = ErrCodecCorrupt
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return x
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+
+	if len(r.buf) == 0 {
+		r.err = ErrCodecCorrupt
+		return 0
+	}
+	x := r.buf[0]
+	r.buf = r.buf[1:]
+	return x
+}
+
+func (r *reader) float64() float64 {
+	if r.err != nil {
+		return 0
+	}
+
+	if len(r.buf) < 8 {
+		r.err = ErrCodecCorrupt
+		return 0
+	}
+	x := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return x
+}
+
+func (r *reader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+
+	if n > uint64(len(r.buf)) {
+		r.err = ErrCodecCorrupt
+		return ""
+	}
+	x := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return x
+}
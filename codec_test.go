@@ -0,0 +1,239 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"hash"
+	"hash/fnv"
+	"math"
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestCodecBinary(t *testing.T) {
+	r := New(M64_Q4096_T256, WithHash(sha256.New), WithZoneAware(), WithBoundedLoad(0.25))
+	for _, node := range randKeys(16) {
+		r.Join(node)
+	}
+	r.JoinMember(Member{ID: "node", Weight: 2.5, Zone: "eu-west-1a", Addr: "10.0.0.1:8080", Tags: map[string]string{"version": "1.2", "os": "linux"}})
+	r.Bootstrap("joining")
+	r.Handoff(r.Members()[0])
+
+	data, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+
+	c := New()
+	it.Ok(t).
+		IfNil(c.UnmarshalBinary(data)).
+		If(c.m).Equal(r.m).
+		If(c.q).Equal(r.q).
+		If(c.t).Equal(r.t).
+		If(c.zoned).Equal(true).
+		If(c.epsilon).Equal(0.25).
+		If(c.arc).Equal(r.arc).
+		If(hashName(c.hasher)).Equal("sha256").
+		If(c.nodes).Equal(r.nodes).
+		If(c.hashes).Equal(r.hashes)
+
+	key := randKey()
+	ap, ah := r.SuccessorOf(3, key)
+	bp, bh := c.SuccessorOf(3, key)
+	it.Ok(t).
+		If(bp).Equal(ap).
+		If(bh).Equal(ah)
+
+	again, err := c.MarshalBinary()
+	it.Ok(t).
+		IfNil(err).
+		If(again).Equal(data)
+}
+
+func TestCodecBinaryEmpty(t *testing.T) {
+	r := New()
+
+	data, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+
+	c := New(WithQ(16))
+	it.Ok(t).
+		IfNil(c.UnmarshalBinary(data)).
+		If(c.Size()).Equal(0).
+		If(c.hashes).Equal(r.hashes)
+}
+
+func TestCodecBinaryCorrupted(t *testing.T) {
+	r := New()
+	for _, node := range randKeys(4) {
+		r.Join(node)
+	}
+
+	data, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+
+	it.Ok(t).
+		If(New().UnmarshalBinary(nil)).Equal(ErrCodecMagic).
+		If(New().UnmarshalBinary([]byte("ring\xff"))).Equal(ErrCodecVersion).
+		If(New().UnmarshalBinary(data[:len(data)-1])).Equal(ErrCodecCorrupt).
+		If(New().UnmarshalBinary(append(data, 0))).Equal(ErrCodecCorrupt)
+}
+
+func TestCodecInvalidMember(t *testing.T) {
+	for _, m := range []member{
+		{state: StateActive, weight: -1},
+		{state: StateActive, weight: math.NaN()},
+		{state: StateActive, weight: math.Inf(1)},
+		{state: State(42), weight: 1},
+	} {
+		r := New()
+		r.Join("a").Join("b")
+		m.meta = r.nodes["a"].meta
+		r.nodes["a"] = m
+
+		bin, err := r.MarshalBinary()
+		it.Ok(t).
+			IfNil(err).
+			If(New().UnmarshalBinary(bin)).Equal(ErrCodecCorrupt)
+
+		// JSON does not encode NaN, Inf and unknown states
+		if js, err := r.MarshalJSON(); err == nil && m.state.valid() {
+			it.Ok(t).IfNotNil(New().UnmarshalJSON(js))
+		}
+	}
+}
+
+func TestCodecBinaryHash(t *testing.T) {
+	_, err := New(WithHash(fnv.New128)).MarshalBinary()
+	it.Ok(t).IfNotNil(err)
+
+	RegisterHash("fnv128a", fnv.New128a)
+	r := New(WithHash(fnv.New128a))
+	data, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+
+	c := New()
+	it.Ok(t).
+		IfNil(c.UnmarshalBinary(data)).
+		If(hashName(c.hasher)).Equal("fnv128a")
+}
+
+func TestCodecRegisterHashConcurrent(t *testing.T) {
+	fnv64 := func() hash.Hash { return fnv.New64() }
+	r := New(WithHash(fnv64))
+	RegisterHash("fnv64", fnv64)
+
+	start, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		<-start
+		for i := 0; i < 1000; i++ {
+			RegisterHash("fnv64", fnv64)
+		}
+	}()
+
+	close(start)
+	for i := 0; i < 1000; i++ {
+		_, err := r.MarshalBinary()
+		it.Ok(t).IfNil(err)
+	}
+	<-done
+}
+
+func TestCodecBinaryFastHash(t *testing.T) {
+	r := New(M64_Q4096_T256, WithXXHash64())
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	data, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+
+	c := New()
+	key := randKey()
+	it.Ok(t).
+		IfNil(c.UnmarshalBinary(data)).
+		If(hashName(c.hasher)).Equal("xxhash64").
+		If(c.LookupKey(key)).Equal(r.LookupKey(key))
+}
+
+func TestCodecJSON(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(16) {
+		r.Join(node)
+	}
+	r.JoinMember(Member{ID: "node", Weight: 0.5, Zone: "eu-west-1a", Addr: "10.0.0.1:8080", Tags: map[string]string{"version": "1.2"}})
+	r.Bootstrap("joining")
+	r.Handoff(r.Members()[0])
+
+	data, err := json.Marshal(r)
+	it.Ok(t).IfNil(err)
+
+	c := New()
+	it.Ok(t).
+		IfNil(json.Unmarshal(data, c)).
+		If(c.m).Equal(r.m).
+		If(c.q).Equal(r.q).
+		If(c.t).Equal(r.t).
+		If(c.arc).Equal(r.arc).
+		If(hashName(c.hasher)).Equal("sha1").
+		If(c.nodes).Equal(r.nodes).
+		If(c.hashes).Equal(r.hashes)
+
+	again, err := json.Marshal(c)
+	it.Ok(t).
+		IfNil(err).
+		If(again).Equal(data)
+}
+
+func TestCodecJSONHash(t *testing.T) {
+	hash := Hash{hash: 0xffffffffffffffff, addr: 0xab26472ec2ed62a, rank: 1, node: "a"}
+
+	data, err := json.Marshal(hash)
+	it.Ok(t).
+		IfNil(err).
+		If(string(data)).Equal(`{"hash":"ffffffffffffffff","addr":"ab26472ec2ed62a","rank":1,"node":"a"}`)
+
+	var x Hash
+	it.Ok(t).
+		IfNil(json.Unmarshal(data, &x)).
+		If(x).Equal(hash)
+}
+
+func TestCodecJSONSuccessors(t *testing.T) {
+	r := New()
+	for _, node := range randKeys(5) {
+		r.Join(node)
+	}
+
+	primary, handoff := r.SuccessorOf(3, randKey())
+	a, err := json.Marshal(primary)
+	it.Ok(t).IfNil(err)
+
+	b, err := json.Marshal(handoff)
+	it.Ok(t).
+		IfNil(err).
+		If(string(b)).Equal("[]")
+
+	var x Primary
+	it.Ok(t).
+		IfNil(json.Unmarshal(a, &x)).
+		If(x).Equal(primary)
+}
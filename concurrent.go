@@ -0,0 +1,451 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+/*
+
+Concurrent is the ring safe for concurrent use. Readers use an immutable
+snapshot of the ring published through the atomic pointer, they never block.
+Writers are serialized, they apply copy-on-write updates to the ring and
+publish the new snapshot when the topology is fully repaired and
+its preference lists are indexed.
+*/
+type Concurrent struct {
+	mutex sync.Mutex
+	ring  atomic.Value
+}
+
+// NewConcurrent creates instance of the ring safe for concurrent use
+func NewConcurrent(opts ...Option) *Concurrent {
+	c := &Concurrent{}
+	c.ring.Store(New(opts...))
+	return c
+}
+
+// clone the ring, the copy does not share mutable state with original
+func (ring *Ring) clone() *Ring {
+	c := *ring
+
+	c.hashes = make(Hashes, len(ring.hashes))
+	copy(c.hashes, ring.hashes)
+
+	c.shards = make([]Node, len(ring.shards))
+	copy(c.shards, ring.shards)
+
+	if ring.claims != nil {
+		c.claims = make([]claims, len(ring.claims))
+		for i, seq := range ring.claims {
+			c.claims[i] = append(claims(nil), seq...)
+		}
+	}
+
+	c.nodes = make(map[string]member, len(ring.nodes))
+	for node, m := range ring.nodes {
+		c.nodes[node] = m
+	}
+
+	return &c
+}
+
+/*
+
+Snapshot returns the current immutable version of the ring.
+The snapshot is consistent, it is not affected by later updates.
+Caller must not modify the snapshot.
+*/
+func (c *Concurrent) Snapshot() *Ring {
+	return c.ring.Load().(*Ring)
+}
+
+/*
+
+Update applies the function to the copy of the ring and publishes it.
+Topology events are emitted to subscribers after the ring is published.
+*/
+func (c *Concurrent) Update(f func(*Ring)) *Ring {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	events := []Event{}
+	ring := c.Snapshot().clone()
+	ring.pending = &events
+	f(ring)
+	ring.pending = nil
+	ring.preferences()
+	c.ring.Store(ring)
+
+	for _, e := range events {
+		ring.observers.notify(e)
+	}
+
+	return ring
+}
+
+/*
+
+Subscribe to topology events. See Ring.Subscribe
+Events are delivered while updates are serialized,
+the function must not update the ring.
+*/
+func (c *Concurrent) Subscribe(f func(Event)) func() {
+	return c.Snapshot().Subscribe(f)
+}
+
+/*
+
+Join node to the ring. See Ring.Join
+*/
+func (c *Concurrent) Join(node string) *Concurrent {
+	c.Update(func(ring *Ring) { ring.Join(node) })
+	return c
+}
+
+/*
+
+JoinWithWeight joins node to the ring. See Ring.JoinWithWeight
+*/
+func (c *Concurrent) JoinWithWeight(node string, weight float64) *Concurrent {
+	c.Update(func(ring *Ring) { ring.JoinWithWeight(node, weight) })
+	return c
+}
+
+/*
+
+JoinMember joins node to the ring. See Ring.JoinMember
+*/
+func (c *Concurrent) JoinMember(node Member) *Concurrent {
+	c.Update(func(ring *Ring) { ring.JoinMember(node) })
+	return c
+}
+
+/*
+
+Leave node from the ring. See Ring.Leave
+*/
+func (c *Concurrent) Leave(node string) *Concurrent {
+	c.Update(func(ring *Ring) { ring.Leave(node) })
+	return c
+}
+
+/*
+
+Handoff node's responsibility. See Ring.Handoff
+*/
+func (c *Concurrent) Handoff(node string) *Concurrent {
+	c.Update(func(ring *Ring) { ring.Handoff(node) })
+	return c
+}
+
+/*
+
+Bootstrap joins node in joining state. See Ring.Bootstrap
+*/
+func (c *Concurrent) Bootstrap(node string) *Concurrent {
+	c.Update(func(ring *Ring) { ring.Bootstrap(node) })
+	return c
+}
+
+/*
+
+Activate the node. See Ring.Activate
+*/
+func (c *Concurrent) Activate(node string) *Concurrent {
+	c.Update(func(ring *Ring) { ring.Activate(node) })
+	return c
+}
+
+/*
+
+Recover node from handoff or down state. See Ring.Recover
+*/
+func (c *Concurrent) Recover(node string) *Concurrent {
+	c.Update(func(ring *Ring) { ring.Recover(node) })
+	return c
+}
+
+/*
+
+Drain node before it leaves the ring. See Ring.Drain
+*/
+func (c *Concurrent) Drain(node string) *Concurrent {
+	c.Update(func(ring *Ring) { ring.Drain(node) })
+	return c
+}
+
+/*
+
+Fail marks node as down. See Ring.Fail
+*/
+func (c *Concurrent) Fail(node string) *Concurrent {
+	c.Update(func(ring *Ring) { ring.Fail(node) })
+	return c
+}
+
+/*
+
+State returns the state of the node. See Ring.State
+*/
+func (c *Concurrent) State(node string) State {
+	return c.Snapshot().State(node)
+}
+
+/*
+
+SuccessorOf return N distinct nodes to route key. See Ring.SuccessorOf
+*/
+func (c *Concurrent) SuccessorOf(n uint64, key string) (Primary, Handoff) {
+	return c.Snapshot().SuccessorOf(n, key)
+}
+
+/*
+
+AppendSuccessorOf appends N distinct nodes to route key. See Ring.AppendSuccessorOf
+*/
+func (c *Concurrent) AppendSuccessorOf(primary Primary, handoff Handoff, n uint64, key string) (Primary, Handoff) {
+	return c.Snapshot().AppendSuccessorOf(primary, handoff, n, key)
+}
+
+/*
+
+GroupBy routes batch of keys grouped by replica set. See Ring.GroupBy
+*/
+func (c *Concurrent) GroupBy(n uint64, keys []string) []Group {
+	return c.Snapshot().GroupBy(n, keys)
+}
+
+/*
+
+Address calculates address of key on the ring
+*/
+func (c *Concurrent) Address(key string) uint64 {
+	return c.Snapshot().Address(key)
+}
+
+/*
+
+AddressBytes calculates address of key on the ring
+*/
+func (c *Concurrent) AddressBytes(key []byte) uint64 {
+	return c.Snapshot().AddressBytes(key)
+}
+
+/*
+
+AddressOf calculates address of hashable key on the ring. See Ring.AddressOf
+*/
+func (c *Concurrent) AddressOf(key Hashable) uint64 {
+	return c.Snapshot().AddressOf(key)
+}
+
+/*
+
+SuccessorOfBytes return N distinct nodes to route key. See Ring.SuccessorOf
+*/
+func (c *Concurrent) SuccessorOfBytes(n uint64, key []byte) (Primary, Handoff) {
+	return c.Snapshot().SuccessorOfBytes(n, key)
+}
+
+/*
+
+SuccessorOfAddr return N distinct nodes to route address. See Ring.SuccessorOf
+*/
+func (c *Concurrent) SuccessorOfAddr(n uint64, addr uint64) (Primary, Handoff) {
+	return c.Snapshot().SuccessorOfAddr(n, addr)
+}
+
+/*
+
+Lookup the address position on the ring
+*/
+func (c *Concurrent) Lookup(addr uint64) Node {
+	return c.Snapshot().Lookup(addr)
+}
+
+/*
+
+LookupKey the key position on the ring
+*/
+func (c *Concurrent) LookupKey(key string) Node {
+	return c.Snapshot().LookupKey(key)
+}
+
+/*
+
+LookupKeyBytes the key position on the ring
+*/
+func (c *Concurrent) LookupKeyBytes(key []byte) Node {
+	return c.Snapshot().LookupKeyBytes(key)
+}
+
+/*
+
+LookupBounded the address position using bounded loads. See Ring.LookupBounded
+*/
+func (c *Concurrent) LookupBounded(addr uint64, load Load) Node {
+	return c.Snapshot().LookupBounded(addr, load)
+}
+
+/*
+
+LookupKeyBounded the key position using bounded loads. See Ring.LookupKeyBounded
+*/
+func (c *Concurrent) LookupKeyBounded(key string, load Load) Node {
+	return c.Snapshot().LookupKeyBounded(key, load)
+}
+
+/*
+
+Before returns list of N predecessors shards for the address.
+*/
+func (c *Concurrent) Before(n uint64, addr uint64) []Node {
+	return c.Snapshot().Before(n, addr)
+}
+
+/*
+
+BeforeKey returns list of N predecessors shards for the key.
+*/
+func (c *Concurrent) BeforeKey(n uint64, key string) []Node {
+	return c.Snapshot().BeforeKey(n, key)
+}
+
+/*
+
+BeforeKeyBytes returns list of N predecessors shards for the key.
+*/
+func (c *Concurrent) BeforeKeyBytes(n uint64, key []byte) []Node {
+	return c.Snapshot().BeforeKeyBytes(n, key)
+}
+
+/*
+
+After returns list of N successors shards for the address.
+*/
+func (c *Concurrent) After(n uint64, addr uint64) []Node {
+	return c.Snapshot().After(n, addr)
+}
+
+/*
+
+AfterKey returns list of N successors shards for the key.
+*/
+func (c *Concurrent) AfterKey(n uint64, key string) []Node {
+	return c.Snapshot().AfterKey(n, key)
+}
+
+/*
+
+AfterKeyBytes returns list of N successors shards for the key.
+*/
+func (c *Concurrent) AfterKeyBytes(n uint64, key []byte) []Node {
+	return c.Snapshot().AfterKeyBytes(n, key)
+}
+
+/*
+
+ShardOf returns index of the shard hit by the key
+*/
+func (c *Concurrent) ShardOf(key string) int {
+	return c.Snapshot().ShardOf(key)
+}
+
+/*
+
+ShardOfAddress returns index of the shard that owns the address
+*/
+func (c *Concurrent) ShardOfAddress(addr uint64) int {
+	return c.Snapshot().ShardOfAddress(addr)
+}
+
+/*
+
+Shard returns the shard by its index
+*/
+func (c *Concurrent) Shard(i int) Node {
+	return c.Snapshot().Shard(i)
+}
+
+/*
+
+Range returns the address range [lo, hi] covered by the shard
+*/
+func (c *Concurrent) Range(i int) (lo, hi uint64) {
+	return c.Snapshot().Range(i)
+}
+
+/*
+
+RangesOf returns address ranges owned by the node. See Ring.RangesOf
+*/
+func (c *Concurrent) RangesOf(n uint64, node string) []Range {
+	return c.Snapshot().RangesOf(n, node)
+}
+
+/*
+
+HintsOf returns shards of the node substituted by handoff nodes. See Ring.HintsOf
+*/
+func (c *Concurrent) HintsOf(n uint64, node string) []Hint {
+	return c.Snapshot().HintsOf(n, node)
+}
+
+/*
+
+Size of ring, number of members joined the ring
+*/
+func (c *Concurrent) Size() int {
+	return c.Snapshot().Size()
+}
+
+/*
+
+Has return true if key exists in the ring
+*/
+func (c *Concurrent) Has(node string) bool {
+	return c.Snapshot().Has(node)
+}
+
+/*
+
+Members return list of nodes registered at ring
+*/
+func (c *Concurrent) Members() []string {
+	return c.Snapshot().Members()
+}
+
+/*
+
+Nodes return list of nodes and its shards
+*/
+func (c *Concurrent) Nodes() map[string][]Node {
+	return c.Snapshot().Nodes()
+}
+
+/*
+
+Shards returns ring topology and its allocation
+*/
+func (c *Concurrent) Shards() []Node {
+	return c.Snapshot().Shards()
+}
@@ -0,0 +1,81 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestConcurrentSnapshot(t *testing.T) {
+	c := NewConcurrent(M64_Q4096_T256)
+	c.Join("a").Join("b")
+
+	snapshot := c.Snapshot()
+	shards := snapshot.Shards()
+
+	c.Join("c").Handoff("a")
+
+	it.Ok(t).
+		If(snapshot.Size()).Equal(2).
+		If(snapshot.Shards()).Equal(shards).
+		If(snapshot.State("a")).Equal(StateActive).
+		If(c.Size()).Equal(3).
+		If(c.State("a")).Equal(StateHandoff)
+
+	r := New(M64_Q4096_T256)
+	r.Join("a").Join("b").Join("c")
+	it.Ok(t).If(c.Shards()).Equal(r.Shards())
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	c := NewConcurrent(M64_Q4096_T256)
+	c.Join("a")
+
+	nodes := randKeys(32)
+	keys := randKeys(64)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range nodes {
+				for _, key := range keys {
+					primary, _ := c.SuccessorOf(3, key)
+					if len(primary) == 0 || c.LookupKey(key).Node() == "" {
+						t.Errorf("key %s is not routed", key)
+					}
+				}
+			}
+		}()
+	}
+
+	for _, node := range nodes {
+		c.Join(node)
+	}
+	for _, node := range nodes[:16] {
+		c.Leave(node)
+	}
+	wg.Wait()
+
+	it.Ok(t).If(c.Size()).Equal(17)
+}
@@ -0,0 +1,115 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIncompatible is returned when rings have different configuration
+var ErrIncompatible = errors.New("ring: incompatible configuration")
+
+// Transfer of shard from one node to another
+type Transfer struct {
+	Shard   int    // index of the shard
+	Lo      uint64 // lowest address of the shard
+	Hi      uint64 // highest address of the shard
+	Replica int    // position of receiving node at the list of successors
+	From    string // node streams the shard, empty if there is no source
+	To      string // node receives the shard
+}
+
+func (t Transfer) String() string {
+	return fmt.Sprintf("{%d [%x, %x] %d: %s ⇒ %s}",
+		t.Shard, t.Lo, t.Hi, t.Replica, t.From, t.To)
+}
+
+/*
+
+Diff returns the migration plan of shards between two versions of the ring.
+The plan contains a transfer for each node that became one of N successors
+of the shard (primary or handoff). The transfer is sourced from the node
+that is not a successor anymore or from the former coordinator of the shard.
+*/
+func Diff(n uint64, before, after *Ring) ([]Transfer, error) {
+	if before.m != after.m || before.q != after.q {
+		return nil, ErrIncompatible
+	}
+
+	seq := make([]Transfer, 0)
+	for shard := 0; shard < int(after.q); shard++ {
+		a := successorNodes(before.successorOf(n, shard))
+		b := successorNodes(after.successorOf(n, shard))
+
+		removed := make([]string, 0, len(a))
+		for _, node := range a {
+			if !contains(b, node) {
+				removed = append(removed, node)
+			}
+		}
+
+		lo, hi := after.span(shard)
+		for replica, node := range b {
+			if contains(a, node) {
+				continue
+			}
+
+			from := ""
+			switch {
+			case len(removed) > 0:
+				from, removed = removed[0], removed[1:]
+			case len(a) > 0:
+				from = a[0]
+			}
+
+			seq = append(seq, Transfer{
+				Shard:   shard,
+				Lo:      lo,
+				Hi:      hi,
+				Replica: replica,
+				From:    from,
+				To:      node,
+			})
+		}
+	}
+
+	return seq, nil
+}
+
+// list of successor nodes in the order of preference
+func successorNodes(primary Primary, handoff Handoff) []string {
+	seq := make([]string, 0, len(primary)+len(handoff))
+	for _, hash := range primary {
+		seq = append(seq, hash.node)
+	}
+	for _, hash := range handoff {
+		seq = append(seq, hash.node)
+	}
+	return seq
+}
+
+func contains(seq []string, node string) bool {
+	for _, x := range seq {
+		if x == node {
+			return true
+		}
+	}
+	return false
+}
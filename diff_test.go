@@ -0,0 +1,110 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestDiffJoin(t *testing.T) {
+	a := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		a.Join(node)
+	}
+
+	b := a.clone()
+	b.Join("node")
+
+	seq, err := Diff(1, a, b)
+	it.Ok(t).IfNil(err)
+
+	moved := 0
+	for i := range a.hashes {
+		if a.hashes[i].node != b.hashes[i].node {
+			moved++
+		}
+	}
+	it.Ok(t).If(len(seq)).Equal(moved)
+
+	for _, tx := range seq {
+		lo, hi := b.span(tx.Shard)
+		it.Ok(t).
+			If(tx.To).Equal("node").
+			If(tx.From).Equal(a.hashes[tx.Shard].node).
+			If(tx.Replica).Equal(0).
+			If(tx.Lo).Equal(lo).
+			If(tx.Hi).Equal(hi).
+			If(b.Lookup(tx.Lo).Node()).Equal("node").
+			If(b.Lookup(tx.Hi).Node()).Equal("node")
+	}
+}
+
+func TestDiffReplica(t *testing.T) {
+	a := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		a.Join(node)
+	}
+	node := a.Members()[0]
+
+	b := a.clone()
+	b.Leave(node)
+
+	seq, err := Diff(3, a, b)
+	it.Ok(t).IfNil(err)
+	it.Ok(t).IfTrue(len(seq) > 0)
+
+	for _, tx := range seq {
+		x := successorNodes(a.successorOf(3, tx.Shard))
+		y := successorNodes(b.successorOf(3, tx.Shard))
+		it.Ok(t).
+			IfTrue(contains(x, node)).
+			IfFalse(contains(x, tx.To)).
+			IfTrue(contains(y, tx.To)).
+			IfTrue(contains(x, tx.From)).
+			If(y[tx.Replica]).Equal(tx.To)
+	}
+}
+
+func TestDiffEmpty(t *testing.T) {
+	a := New()
+	b := New().Join("a")
+
+	seq, err := Diff(3, a, b)
+	it.Ok(t).
+		IfNil(err).
+		If(len(seq)).Equal(8)
+
+	for _, tx := range seq {
+		it.Ok(t).
+			If(tx.From).Equal("").
+			If(tx.To).Equal("a")
+	}
+
+	seq, err = Diff(3, a, a)
+	it.Ok(t).
+		IfNil(err).
+		If(len(seq)).Equal(0)
+}
+
+func TestDiffIncompatible(t *testing.T) {
+	_, err := Diff(1, New(), New(WithQ(16)))
+	it.Ok(t).If(err).Equal(ErrIncompatible)
+}
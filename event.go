@@ -0,0 +1,180 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EventType is a kind of topology change
+type EventType int
+
+// Kinds of topology changes
+const (
+	EventJoin  EventType = iota + 1 // node joined the ring
+	EventLeave                      // node left the ring
+	EventState                      // node changed its state (e.g. handoff)
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventJoin:
+		return "join"
+	case EventLeave:
+		return "leave"
+	case EventState:
+		return "state"
+	default:
+		return "unknown"
+	}
+}
+
+/*
+
+Event is a topology change. It carries the node, its old and new states and
+list of shards whose owner has been changed by the mutation.
+*/
+type Event struct {
+	Type   EventType
+	Node   string
+	Old    State
+	New    State
+	Shards []Transfer
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("{%s %s: %s ⇒ %s, %d shards}",
+		e.Type, e.Node, e.Old, e.New, len(e.Shards))
+}
+
+// subscribers to topology events
+type observers struct {
+	sync.Mutex
+	id  int
+	seq []observer
+}
+
+type observer struct {
+	id int
+	f  func(Event)
+}
+
+func (obs *observers) subscribe(f func(Event)) func() {
+	obs.Lock()
+	defer obs.Unlock()
+
+	obs.id++
+	id := obs.id
+	obs.seq = append(obs.seq, observer{id: id, f: f})
+
+	return func() {
+		obs.Lock()
+		defer obs.Unlock()
+
+		for i, x := range obs.seq {
+			if x.id == id {
+				obs.seq = append(obs.seq[:i:i], obs.seq[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (obs *observers) size() int {
+	if obs == nil {
+		return 0
+	}
+
+	obs.Lock()
+	defer obs.Unlock()
+	return len(obs.seq)
+}
+
+func (obs *observers) notify(e Event) {
+	obs.Lock()
+	seq := obs.seq
+	obs.Unlock()
+
+	for _, x := range seq {
+		x.f(e)
+	}
+}
+
+/*
+
+Subscribe to topology events. The function is called synchronously after
+each mutation of the ring (Join, Leave, Handoff and other state transitions),
+which has changed the state of node or owners of shards.
+It returns the function to cancel the subscription.
+*/
+func (ring *Ring) Subscribe(f func(Event)) func() {
+	return ring.observers.subscribe(f)
+}
+
+// observe the mutation of the ring, it returns the function
+// that emits the event to subscribers when mutation is completed
+func (ring *Ring) observe(t EventType, node string) func() {
+	if ring.observers.size() == 0 {
+		return func() {}
+	}
+
+	before := make(Hashes, len(ring.hashes))
+	copy(before, ring.hashes)
+	state := ring.State(node)
+
+	return func() {
+		e := Event{
+			Type:   t,
+			Node:   node,
+			Old:    state,
+			New:    ring.State(node),
+			Shards: ring.moved(before),
+		}
+
+		if e.Old == e.New && len(e.Shards) == 0 {
+			return
+		}
+
+		if ring.pending != nil {
+			*ring.pending = append(*ring.pending, e)
+			return
+		}
+
+		ring.observers.notify(e)
+	}
+}
+
+// list of shards whose owner has been changed
+func (ring *Ring) moved(before Hashes) []Transfer {
+	seq := make([]Transfer, 0)
+	for shard, hash := range ring.hashes {
+		if before[shard].node != hash.node {
+			lo, hi := ring.span(shard)
+			seq = append(seq, Transfer{
+				Shard: shard,
+				Lo:    lo,
+				Hi:    hi,
+				From:  before[shard].node,
+				To:    hash.node,
+			})
+		}
+	}
+	return seq
+}
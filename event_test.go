@@ -0,0 +1,110 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestEvents(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	r.Join("a").Join("b")
+
+	events := []Event{}
+	cancel := r.Subscribe(func(e Event) { events = append(events, e) })
+
+	before := r.clone()
+	r.Join("c")
+	moved, _ := Diff(1, before, r)
+	it.Ok(t).
+		If(len(events)).Equal(1).
+		If(events[0].Type).Equal(EventJoin).
+		If(events[0].Node).Equal("c").
+		If(events[0].Old).Equal(StateUnknown).
+		If(events[0].New).Equal(StateActive).
+		If(events[0].Shards).Equal(moved)
+
+	r.Handoff("c").Recover("c").Handoff("x")
+	it.Ok(t).
+		If(len(events)).Equal(3).
+		If(events[1].Type).Equal(EventState).
+		If(events[1].Old).Equal(StateActive).
+		If(events[1].New).Equal(StateHandoff).
+		If(len(events[1].Shards)).Equal(0).
+		If(events[2].Old).Equal(StateHandoff).
+		If(events[2].New).Equal(StateActive)
+
+	r.Join("c").Leave("x")
+	it.Ok(t).If(len(events)).Equal(3)
+
+	r.Leave("c")
+	it.Ok(t).
+		If(len(events)).Equal(4).
+		If(events[3].Type).Equal(EventLeave).
+		If(events[3].New).Equal(StateUnknown).
+		If(len(events[3].Shards)).Equal(len(events[0].Shards))
+
+	for _, tx := range events[3].Shards {
+		it.Ok(t).
+			If(tx.From).Equal("c").
+			If(r.hashes[tx.Shard].node).Equal(tx.To)
+	}
+
+	cancel()
+	r.Join("d")
+	it.Ok(t).If(len(events)).Equal(4)
+}
+
+func TestEventsConcurrent(t *testing.T) {
+	c := NewConcurrent(M64_Q4096_T256)
+	c.Join("a")
+
+	events := []Event{}
+	c.Subscribe(func(e Event) {
+		it.Ok(t).If(c.State(e.Node)).Equal(e.New)
+		events = append(events, e)
+	})
+
+	c.Join("b").Bootstrap("c").Activate("c").Fail("a")
+	it.Ok(t).If(len(events)).Equal(4)
+}
+
+func TestEventsDecoded(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	r.Join("a").Join("b")
+
+	bin, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+	js, err := r.MarshalJSON()
+	it.Ok(t).IfNil(err)
+
+	var x, y Ring
+	it.Ok(t).
+		IfNil(x.UnmarshalBinary(bin)).
+		IfNil(y.UnmarshalJSON(js))
+
+	for _, ring := range []*Ring{&x, &y} {
+		events := []Event{}
+		ring.Subscribe(func(e Event) { events = append(events, e) })
+		ring.Join("c").Handoff("a")
+		it.Ok(t).If(len(events)).Equal(2)
+	}
+}
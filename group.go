@@ -0,0 +1,85 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import "strings"
+
+/*
+
+Group of keys routed to the same replica set. Nodes of the group carry
+the address of shard hit by the first key of the group.
+*/
+type Group struct {
+	Primary Primary
+	Handoff Handoff
+	Keys    []string
+}
+
+/*
+
+GroupBy routes batch of keys, it groups keys by replica set of N distinct
+nodes (see SuccessorOf) so that each replica set handles its keys at once.
+Replica set of each shard is resolved once. Groups are ordered by
+the first appearance of their keys.
+*/
+func (ring *Ring) GroupBy(n uint64, keys []string) []Group {
+	groups := make([]Group, 0)
+	byShard := map[int]int{}
+	bySet := map[string]int{}
+
+	for _, key := range keys {
+		shard, _ := ring.address(key)
+
+		g, exists := byShard[shard]
+		if !exists {
+			primary, handoff := ring.successorOf(n, shard)
+			set := replicaSet(primary, handoff)
+
+			g, exists = bySet[set]
+			if !exists {
+				g = len(groups)
+				groups = append(groups, Group{Primary: primary, Handoff: handoff})
+				bySet[set] = g
+			}
+			byShard[shard] = g
+		}
+
+		groups[g].Keys = append(groups[g].Keys, key)
+	}
+
+	return groups
+}
+
+// identity of replica set, the ordered list of nodes,
+// handoff nodes are paired with primaries they substitute
+func replicaSet(primary Primary, handoff Handoff) string {
+	var b strings.Builder
+	for _, x := range primary {
+		b.WriteString(x.node)
+		b.WriteByte(0)
+	}
+	b.WriteByte(0)
+	for _, x := range handoff {
+		b.WriteString(x.node)
+		b.WriteByte(1)
+		b.WriteString(x.hint)
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestGroupBy(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+	r.Handoff(r.Members()[0])
+
+	keys := randKeys(1000)
+	groups := r.GroupBy(3, keys)
+
+	seen := 0
+	sets := map[string]bool{}
+	for _, g := range groups {
+		set := replicaSet(g.Primary, g.Handoff)
+		it.Ok(t).IfFalse(sets[set])
+		sets[set] = true
+
+		for _, key := range g.Keys {
+			primary, handoff := r.SuccessorOf(3, key)
+			it.Ok(t).If(replicaSet(primary, handoff)).Equal(set)
+			seen++
+		}
+	}
+
+	primary, handoff := r.SuccessorOf(3, keys[0])
+	it.Ok(t).
+		If(seen).Equal(len(keys)).
+		If(groups[0].Keys[0]).Equal(keys[0]).
+		If(groups[0].Primary).Equal(primary).
+		If(groups[0].Handoff).Equal(handoff).
+		If(len(r.GroupBy(3, nil))).Equal(0)
+}
+
+func TestGroupByHint(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	nodes := randKeys(5)
+	for _, node := range nodes {
+		r.Join(node)
+	}
+	r.Handoff(nodes[0]).Handoff(nodes[1])
+
+	for _, g := range r.GroupBy(3, randKeys(5000)) {
+		for _, key := range g.Keys {
+			_, handoff := r.SuccessorOf(3, key)
+			it.Ok(t).If(len(g.Handoff)).Equal(len(handoff))
+			for i := range handoff {
+				it.Ok(t).If(g.Handoff[i].Hint()).Equal(handoff[i].Hint())
+			}
+		}
+	}
+}
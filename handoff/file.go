@@ -0,0 +1,275 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package handoff
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+
+File is file-backed store of hints. Hints of each node are appended
+to the log file in the directory. Delivered hints are removed from the log
+by rewriting it, the log is replaced atomically.
+*/
+type File struct {
+	mutex sync.Mutex
+	dir   string
+	seq   map[string]uint64
+}
+
+var _ Store = (*File)(nil)
+
+// NewFile creates file-backed store of hints at the directory
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &File{dir: dir, seq: map[string]uint64{}}, nil
+}
+
+// log file of the node, node name is encoded to be file system safe
+func (store *File) path(node string) string {
+	return filepath.Join(store.dir, hex.EncodeToString([]byte(node))+".hints")
+}
+
+// Put appends hint to the log of the node
+func (store *File) Put(hint Hint) (uint64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	seq, exists := store.seq[hint.Node]
+	if !exists {
+		hints, size, err := store.read(hint.Node)
+		if err != nil {
+			return 0, err
+		}
+		if len(hints) != 0 {
+			seq = hints[len(hints)-1].Seq
+		}
+
+		// partially written tail record is discarded before appending
+		if err := os.Truncate(store.path(hint.Node), size); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+
+	hint.Seq = seq + 1
+
+	fd, err := os.OpenFile(store.path(hint.Node), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	if _, err := fd.Write(encode(hint)); err != nil {
+		return 0, err
+	}
+
+	if err := fd.Sync(); err != nil {
+		return 0, err
+	}
+
+	store.seq[hint.Node] = hint.Seq
+	return hint.Seq, nil
+}
+
+// Hints returns hints of the node in the order of writes
+func (store *File) Hints(node string) ([]Hint, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	hints, _, err := store.read(node)
+	return hints, err
+}
+
+// Delete hints of the node, the log is rewritten
+func (store *File) Delete(node string, seq ...uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	hints, _, err := store.read(node)
+	if err != nil {
+		return err
+	}
+
+	hints = without(hints, seq)
+	if len(hints) == 0 {
+		// the sequence is kept in-memory, it remains monotonic
+		if err := os.Remove(store.path(node)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	buf := make([]byte, 0)
+	for _, hint := range hints {
+		buf = append(buf, encode(hint)...)
+	}
+
+	tmp := store.path(node) + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, store.path(node))
+}
+
+// read the log of the node, partially written tail record is ignored.
+// It returns hints and size of complete records.
+func (store *File) read(node string) ([]Hint, int64, error) {
+	fd, err := os.Open(store.path(node))
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer fd.Close()
+
+	fi, err := fd.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r := bufio.NewReader(fd)
+	hints := make([]Hint, 0)
+	size := int64(0)
+	for {
+		hint, n, err := decode(r, fi.Size()-size)
+		switch {
+		case err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF):
+			return hints, size, nil
+		case err != nil:
+			return nil, 0, err
+		}
+
+		hint.Node = node
+		hints = append(hints, hint)
+		size += n
+	}
+}
+
+/*
+
+encodes hint as the length prefixed record
+
+	len | seq | created | key | value
+
+node is not encoded, it is defined by the log file.
+*/
+func encode(hint Hint) []byte {
+	rec := make([]byte, 0, 2*binary.MaxVarintLen64+len(hint.Key)+len(hint.Value)+16)
+	rec = appendUvarint(rec, hint.Seq)
+	rec = appendVarint(rec, hint.Created.UnixNano())
+	rec = appendUvarint(rec, uint64(len(hint.Key)))
+	rec = append(rec, hint.Key...)
+	rec = appendUvarint(rec, uint64(len(hint.Value)))
+	rec = append(rec, hint.Value...)
+
+	buf := appendUvarint(make([]byte, 0, len(rec)+binary.MaxVarintLen64), uint64(len(rec)))
+	return append(buf, rec...)
+}
+
+// decodes hint from remaining bytes of the log, it returns size of the record.
+// The record longer than remaining bytes is the partially written tail.
+func decode(r *bufio.Reader, remain int64) (Hint, int64, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return Hint{}, 0, err
+	}
+
+	prefix := int64(len(appendUvarint(nil, size)))
+	if remain < prefix || size > uint64(remain-prefix) {
+		return Hint{}, 0, io.ErrUnexpectedEOF
+	}
+
+	rec := make([]byte, size)
+	if _, err := io.ReadFull(r, rec); err != nil {
+		return Hint{}, 0, err
+	}
+	n := prefix + int64(size)
+
+	hint, err := decodeRecord(rec)
+	return hint, n, err
+}
+
+func decodeRecord(rec []byte) (Hint, error) {
+	var hint Hint
+	var n int
+
+	hint.Seq, n = binary.Uvarint(rec)
+	if n <= 0 {
+		return Hint{}, errMalformed
+	}
+	rec = rec[n:]
+
+	created, n := binary.Varint(rec)
+	if n <= 0 {
+		return Hint{}, errMalformed
+	}
+	hint.Created = time.Unix(0, created)
+	rec = rec[n:]
+
+	key, rec, err := chunk(rec)
+	if err != nil {
+		return Hint{}, err
+	}
+	hint.Key = string(key)
+
+	value, _, err := chunk(rec)
+	if err != nil {
+		return Hint{}, err
+	}
+	hint.Value = value
+
+	return hint, nil
+}
+
+var errMalformed = errors.New("handoff: malformed hint record")
+
+func chunk(rec []byte) ([]byte, []byte, error) {
+	size, n := binary.Uvarint(rec)
+	if n <= 0 || uint64(len(rec)-n) < size {
+		return nil, nil, errMalformed
+	}
+
+	return rec[n : n+int(size)], rec[n+int(size):], nil
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], x)
+	return append(buf, b[:n]...)
+}
+
+func appendVarint(buf []byte, x int64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], x)
+	return append(buf, b[:n]...)
+}
@@ -0,0 +1,94 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+/*
+
+Package handoff implements hinted handoff. Writes destined to unavailable
+nodes (the ring substitutes them by handoff nodes) are recorded to the store
+as hints. The replayer delivers hints to the node through the transport
+once the node is active again.
+*/
+package handoff
+
+import (
+	"context"
+	"time"
+
+	"github.com/fogfish/ring"
+)
+
+// Hint is the write destined to the unavailable node
+type Hint struct {
+	Seq     uint64    // sequence number of hint, assigned by the store
+	Node    string    // destination node, the substituted primary
+	Key     string    // key of the write
+	Value   []byte    // value of the write
+	Created time.Time // time of the write
+}
+
+// Store of hints, hints of the node are ordered by sequence number
+type Store interface {
+	// Put appends hint to the store, it returns hint's sequence number
+	Put(hint Hint) (uint64, error)
+
+	// Hints returns hints of the node in the order of writes
+	Hints(node string) ([]Hint, error)
+
+	// Delete delivered or expired hints of the node
+	Delete(node string, seq ...uint64) error
+}
+
+// Transport delivers hint to the node
+type Transport interface {
+	Deliver(ctx context.Context, node string, hint Hint) error
+}
+
+// TransportFunc is the function that implements Transport
+type TransportFunc func(ctx context.Context, node string, hint Hint) error
+
+// Deliver hint to the node
+func (f TransportFunc) Deliver(ctx context.Context, node string, hint Hint) error {
+	return f(ctx, node, hint)
+}
+
+/*
+
+Record the write as hints for primary nodes substituted by handoff nodes
+(see ring.Hash.Hint). It returns number of recorded hints.
+*/
+func Record(store Store, handoff ring.Handoff, key string, value []byte) (int, error) {
+	n := 0
+	for _, node := range handoff {
+		if node.Hint() == "" {
+			continue
+		}
+
+		_, err := store.Put(Hint{
+			Node:    node.Hint(),
+			Key:     key,
+			Value:   value,
+			Created: time.Now(),
+		})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
@@ -0,0 +1,260 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package handoff_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fogfish/it"
+	"github.com/fogfish/ring"
+	"github.com/fogfish/ring/handoff"
+)
+
+func stores(t *testing.T) map[string]handoff.Store {
+	file, err := handoff.NewFile(t.TempDir())
+	it.Ok(t).IfNil(err)
+
+	return map[string]handoff.Store{
+		"memory": handoff.NewMemory(),
+		"file":   file,
+	}
+}
+
+func TestStore(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Unix(0, time.Now().UnixNano())
+			for i := 0; i < 4; i++ {
+				seq, err := store.Put(handoff.Hint{
+					Node:    "a",
+					Key:     fmt.Sprintf("k%d", i),
+					Value:   []byte{byte(i)},
+					Created: now,
+				})
+				it.Ok(t).IfNil(err).If(seq).Equal(uint64(i + 1))
+			}
+
+			hints, err := store.Hints("a")
+			it.Ok(t).
+				IfNil(err).
+				If(len(hints)).Equal(4).
+				If(hints[2]).Equal(handoff.Hint{Seq: 3, Node: "a", Key: "k2", Value: []byte{2}, Created: now})
+
+			none, err := store.Hints("b")
+			it.Ok(t).IfNil(err).If(len(none)).Equal(0)
+
+			it.Ok(t).IfNil(store.Delete("a", 1, 3))
+			hints, err = store.Hints("a")
+			it.Ok(t).
+				IfNil(err).
+				If(len(hints)).Equal(2).
+				If(hints[0].Seq).Equal(uint64(2)).
+				If(hints[1].Seq).Equal(uint64(4))
+
+			it.Ok(t).IfNil(store.Delete("a", 2, 4))
+			hints, err = store.Hints("a")
+			it.Ok(t).IfNil(err).If(len(hints)).Equal(0)
+
+			seq, err := store.Put(handoff.Hint{Node: "a", Key: "k"})
+			it.Ok(t).IfNil(err).If(seq).Equal(uint64(5))
+		})
+	}
+}
+
+func TestFileRecovery(t *testing.T) {
+	dir := t.TempDir()
+	store, err := handoff.NewFile(dir)
+	it.Ok(t).IfNil(err)
+
+	for i := 0; i < 3; i++ {
+		_, err := store.Put(handoff.Hint{Node: "a", Key: "k", Value: []byte("value")})
+		it.Ok(t).IfNil(err)
+	}
+
+	// partially written tail record
+	files, _ := filepath.Glob(filepath.Join(dir, "*.hints"))
+	it.Ok(t).If(len(files)).Equal(1)
+	fd, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0o644)
+	it.Ok(t).IfNil(err)
+	_, err = fd.Write([]byte{0x20, 0x01, 0x02})
+	it.Ok(t).IfNil(err).IfNil(fd.Close())
+
+	reopen, err := handoff.NewFile(dir)
+	it.Ok(t).IfNil(err)
+
+	hints, err := reopen.Hints("a")
+	it.Ok(t).IfNil(err).If(len(hints)).Equal(3)
+
+	for i := 0; i < 5; i++ {
+		seq, err := reopen.Put(handoff.Hint{Node: "a", Key: "k", Value: []byte("value")})
+		it.Ok(t).IfNil(err).If(seq).Equal(uint64(4 + i))
+	}
+
+	hints, err = reopen.Hints("a")
+	it.Ok(t).IfNil(err).If(len(hints)).Equal(8)
+	for i, hint := range hints {
+		it.Ok(t).
+			If(hint.Seq).Equal(uint64(i + 1)).
+			If(hint.Value).Equal([]byte("value"))
+	}
+}
+
+func TestFileCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	store, err := handoff.NewFile(dir)
+	it.Ok(t).IfNil(err)
+
+	_, err = store.Put(handoff.Hint{Node: "a", Key: "k", Value: []byte("value")})
+	it.Ok(t).IfNil(err)
+
+	// length prefix of the record exceeds the log
+	files, _ := filepath.Glob(filepath.Join(dir, "*.hints"))
+	fd, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0o644)
+	it.Ok(t).IfNil(err)
+	_, err = fd.Write([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x00})
+	it.Ok(t).IfNil(err).IfNil(fd.Close())
+
+	reopen, err := handoff.NewFile(dir)
+	it.Ok(t).IfNil(err)
+
+	hints, err := reopen.Hints("a")
+	it.Ok(t).IfNil(err).If(len(hints)).Equal(1)
+
+	seq, err := reopen.Put(handoff.Hint{Node: "a", Key: "k"})
+	it.Ok(t).IfNil(err).If(seq).Equal(uint64(2))
+
+	hints, err = reopen.Hints("a")
+	it.Ok(t).IfNil(err).If(len(hints)).Equal(2)
+}
+
+// transport fails delivery of the key until it is enabled
+type transport struct {
+	sync.Mutex
+	failed    map[string]bool
+	delivered []handoff.Hint
+}
+
+func (t *transport) Deliver(ctx context.Context, node string, hint handoff.Hint) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.failed[hint.Key] {
+		return errors.New("unavailable")
+	}
+	t.delivered = append(t.delivered, hint)
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	now := time.Now()
+	store := handoff.NewMemory()
+	for _, key := range []string{"a", "b", "a", "c", "b"} {
+		store.Put(handoff.Hint{Node: "n", Key: key, Value: []byte(key), Created: now})
+	}
+	store.Put(handoff.Hint{Node: "n", Key: "d", Created: now.Add(-2 * time.Hour)})
+
+	net := &transport{failed: map[string]bool{"b": true}}
+	replayer := handoff.NewReplayer(store, net,
+		handoff.WithTTL(time.Hour),
+		handoff.WithRetry(2, time.Millisecond),
+	)
+
+	stats, err := replayer.Replay(context.Background(), "n")
+	it.Ok(t).
+		IfNotNil(err).
+		If(stats).Equal(handoff.Stats{Delivered: 3, Expired: 1, Pending: 2}).
+		If(len(net.delivered)).Equal(3)
+
+	pending, _ := store.Hints("n")
+	it.Ok(t).
+		If(len(pending)).Equal(2).
+		If(pending[0].Seq < pending[1].Seq).Equal(true).
+		If(pending[0].Key).Equal("b")
+
+	net.failed["b"] = false
+	stats, err = replayer.Replay(context.Background(), "n")
+	it.Ok(t).
+		IfNil(err).
+		If(stats).Equal(handoff.Stats{Delivered: 2}).
+		If(net.delivered[3].Seq < net.delivered[4].Seq).Equal(true)
+
+	pending, _ = store.Hints("n")
+	it.Ok(t).If(len(pending)).Equal(0)
+}
+
+func TestReplayCancel(t *testing.T) {
+	store := handoff.NewMemory()
+	store.Put(handoff.Hint{Node: "n", Key: "a", Created: time.Now()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	net := &transport{failed: map[string]bool{"a": true}}
+	replayer := handoff.NewReplayer(store, net, handoff.WithRetry(5, time.Hour))
+
+	stats, err := replayer.Replay(ctx, "n")
+	it.Ok(t).
+		If(errors.Is(err, context.Canceled)).Equal(true).
+		If(stats.Pending).Equal(1)
+}
+
+func TestWatch(t *testing.T) {
+	r := ring.NewConcurrent(ring.M64_Q4096_T256)
+	for i := 0; i < 8; i++ {
+		r.Join(fmt.Sprintf("node-%d", i))
+	}
+
+	key := "key"
+	primary, _ := r.SuccessorOf(3, key)
+	node := primary[0].Node()
+	r.Handoff(node)
+
+	store := handoff.NewMemory()
+	_, seq := r.SuccessorOf(3, key)
+	n, err := handoff.Record(store, seq, key, []byte("value"))
+	it.Ok(t).IfNil(err).If(n).Equal(1)
+
+	net := &transport{}
+	replayer := handoff.NewReplayer(store, net)
+
+	done := make(chan handoff.Stats, 1)
+	cancel := replayer.Watch(context.Background(), r,
+		func(_ string, stats handoff.Stats, _ error) { done <- stats },
+	)
+	defer cancel()
+
+	r.Recover(node)
+
+	select {
+	case stats := <-done:
+		it.Ok(t).
+			If(stats.Delivered).Equal(1).
+			If(net.delivered[0].Node).Equal(node).
+			If(net.delivered[0].Key).Equal(key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay is not triggered")
+	}
+}
@@ -0,0 +1,87 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package handoff
+
+import "sync"
+
+// Memory is in-memory store of hints
+type Memory struct {
+	mutex sync.Mutex
+	seq   uint64
+	hints map[string][]Hint
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory creates in-memory store of hints
+func NewMemory() *Memory {
+	return &Memory{hints: map[string][]Hint{}}
+}
+
+// Put appends hint to the store
+func (store *Memory) Put(hint Hint) (uint64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.seq++
+	hint.Seq = store.seq
+	hint.Value = append([]byte(nil), hint.Value...)
+	store.hints[hint.Node] = append(store.hints[hint.Node], hint)
+
+	return hint.Seq, nil
+}
+
+// Hints returns hints of the node in the order of writes
+func (store *Memory) Hints(node string) ([]Hint, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return append([]Hint(nil), store.hints[node]...), nil
+}
+
+// Delete hints of the node
+func (store *Memory) Delete(node string, seq ...uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	hints := without(store.hints[node], seq)
+	if len(hints) == 0 {
+		delete(store.hints, node)
+		return nil
+	}
+
+	store.hints[node] = hints
+	return nil
+}
+
+// returns copy of hints without given sequence numbers
+func without(hints []Hint, seq []uint64) []Hint {
+	drop := make(map[uint64]struct{}, len(seq))
+	for _, x := range seq {
+		drop[x] = struct{}{}
+	}
+
+	keep := make([]Hint, 0, len(hints))
+	for _, hint := range hints {
+		if _, exists := drop[hint.Seq]; !exists {
+			keep = append(keep, hint)
+		}
+	}
+	return keep
+}
@@ -0,0 +1,233 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fogfish/ring"
+)
+
+// Option of the replayer
+type Option func(*Replayer)
+
+// WithTTL defines time-to-live of hints, expired hints are dropped
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Replayer) {
+		r.ttl = ttl
+	}
+}
+
+// WithRetry defines number of delivery attempts and the initial backoff
+// between them, the backoff is doubled on each attempt.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(r *Replayer) {
+		r.attempts = attempts
+		r.backoff = backoff
+	}
+}
+
+// WithClock defines the clock used to expire hints
+func WithClock(clock func() time.Time) Option {
+	return func(r *Replayer) {
+		r.clock = clock
+	}
+}
+
+// Default configuration of the replayer
+const (
+	DefaultTTL      = 3 * time.Hour
+	DefaultAttempts = 3
+	DefaultBackoff  = 100 * time.Millisecond
+)
+
+/*
+
+Replayer delivers hints to the node once it is active again.
+Hints of the same key are delivered in the order of writes, the delivery
+of key is suspended after the failure of hint, remaining hints of the key
+are retained for the next replay. Replays of the same node are serialized.
+*/
+type Replayer struct {
+	store     Store
+	transport Transport
+	ttl       time.Duration
+	attempts  int
+	backoff   time.Duration
+	clock     func() time.Time
+
+	mutex sync.Mutex
+	nodes map[string]*sync.Mutex
+}
+
+// NewReplayer creates replayer of hints from the store
+func NewReplayer(store Store, transport Transport, opts ...Option) *Replayer {
+	r := &Replayer{
+		store:     store,
+		transport: transport,
+		ttl:       DefaultTTL,
+		attempts:  DefaultAttempts,
+		backoff:   DefaultBackoff,
+		clock:     time.Now,
+		nodes:     map[string]*sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.attempts < 1 {
+		r.attempts = 1
+	}
+
+	return r
+}
+
+// Stats of the replay
+type Stats struct {
+	Delivered int // hints delivered to the node
+	Expired   int // hints dropped due to TTL
+	Pending   int // hints retained for the next replay
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("{delivered %d, expired %d, pending %d}", s.Delivered, s.Expired, s.Pending)
+}
+
+// lock serializes replays of the node
+func (r *Replayer) lock(node string) func() {
+	r.mutex.Lock()
+	lock, exists := r.nodes[node]
+	if !exists {
+		lock = &sync.Mutex{}
+		r.nodes[node] = lock
+	}
+	r.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+/*
+
+Replay delivers hints of the node through the transport. Delivered and
+expired hints are deleted from the store. It returns the error of the last
+failed delivery if any hint remains pending.
+*/
+func (r *Replayer) Replay(ctx context.Context, node string) (Stats, error) {
+	defer r.lock(node)()
+
+	stats := Stats{}
+	hints, err := r.store.Hints(node)
+	if err != nil {
+		return stats, err
+	}
+
+	var failure error
+	done := make([]uint64, 0, len(hints))
+	suspended := map[string]struct{}{}
+	now := r.clock()
+
+	for _, hint := range hints {
+		if r.ttl > 0 && now.Sub(hint.Created) > r.ttl {
+			done = append(done, hint.Seq)
+			stats.Expired++
+			continue
+		}
+
+		if _, exists := suspended[hint.Key]; exists {
+			stats.Pending++
+			continue
+		}
+
+		if err := r.deliver(ctx, node, hint); err != nil {
+			suspended[hint.Key] = struct{}{}
+			failure = err
+			stats.Pending++
+			continue
+		}
+
+		done = append(done, hint.Seq)
+		stats.Delivered++
+	}
+
+	if len(done) != 0 {
+		if err := r.store.Delete(node, done...); err != nil {
+			return stats, err
+		}
+	}
+
+	if failure != nil {
+		return stats, fmt.Errorf("handoff: %d hints of %s are pending: %w", stats.Pending, node, failure)
+	}
+
+	return stats, nil
+}
+
+// deliver hint with retries
+func (r *Replayer) deliver(ctx context.Context, node string, hint Hint) error {
+	backoff := r.backoff
+
+	var err error
+	for attempt := 0; attempt < r.attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		if err = r.transport.Deliver(ctx, node, hint); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+/*
+
+Watch subscribes the replayer to topology events of the ring. Hints are
+replayed asynchronously when the node is recovered from handoff or down
+state. The callback receives the outcome of each replay, it might be nil.
+It returns the function to cancel the subscription.
+*/
+func (r *Replayer) Watch(ctx context.Context, c *ring.Concurrent, callback func(string, Stats, error)) func() {
+	return c.Subscribe(func(e ring.Event) {
+		if e.Type != ring.EventState || e.New != ring.StateActive {
+			return
+		}
+
+		if e.Old != ring.StateHandoff && e.Old != ring.StateDown {
+			return
+		}
+
+		go func() {
+			stats, err := r.Replay(ctx, e.Node)
+			if callback != nil {
+				callback(e.Node, stats, err)
+			}
+		}()
+	})
+}
@@ -27,19 +27,35 @@ type Node interface {
 	Hash() uint64
 	Rank() int
 	Node() string
+	Member() Member
 }
 
 // Hash value on the ring
 type Hash struct {
-	hash uint64 // consistent hash
-	addr uint64 // address
-	rank int    // rand of the address
-	node string // identifier
+	hash uint64  // consistent hash
+	addr uint64  // address
+	rank int     // rand of the address
+	node string  // identifier
+	meta *Member // descriptor of the node
+	hint string  // unavailable node substituted by the handoff node
 }
 
 func (hash Hash) Hash() uint64 { return hash.hash }
 func (hash Hash) Rank() int    { return hash.rank }
 func (hash Hash) Node() string { return hash.node }
+
+// Hint returns the unavailable (handoff or down) node substituted by
+// the handoff node. It is empty for primary nodes and for handoff nodes
+// substituting joining or leaving primaries, which do not need hinted writes.
+func (hash Hash) Hint() string { return hash.hint }
+
+// Member returns descriptor of the node, tags must not be modified
+func (hash Hash) Member() Member {
+	if hash.meta == nil {
+		return Member{ID: hash.node}
+	}
+	return *hash.meta
+}
 func (hash Hash) String() string {
 	return fmt.Sprintf("{%x | %d - %s}",
 		hash.hash, hash.rank, hash.node)
@@ -72,6 +88,39 @@ func (hashes Hashes) contains(node string) bool {
 	return false
 }
 
+// Token claimed by the node
+type claim struct {
+	shard int    // shard hit by the token
+	addr  uint64 // address of the token
+	rank  int    // rank of the token
+	node  string // identifier
+}
+
+// List of tokens
+type claims []claim
+
+func (seq claims) contains(node string) bool {
+	for _, x := range seq {
+		if x.node == node {
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns copy of list without tokens of the node
+func (seq claims) without(node string) claims {
+	tokens := make(claims, 0, len(seq))
+	for _, x := range seq {
+		if x.node != node {
+			tokens = append(tokens, x)
+		}
+	}
+
+	return tokens
+}
+
 // List of primary hashes/nodes
 type Primary Hashes
 
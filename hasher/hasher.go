@@ -0,0 +1,44 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+/*
+
+Package hasher implements fast non-cryptographic hashing algorithms for
+the ring: xxHash64, Murmur3 (x64, 128-bit) and SipHash-2-4. Algorithms
+implement hash.Hash64, the digest is big-endian as required by hash.Hash64
+so that the first 8 bytes of Sum are equal to Sum64.
+*/
+package hasher
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+func rotl(x uint64, k int) uint64 { return bits.RotateLeft64(x, k) }
+
+func u64(b []byte) uint64 { return binary.LittleEndian.Uint64(b) }
+
+func u32(b []byte) uint32 { return binary.LittleEndian.Uint32(b) }
+
+func appendUint64(b []byte, x uint64) []byte {
+	return append(b,
+		byte(x>>56), byte(x>>48), byte(x>>40), byte(x>>32),
+		byte(x>>24), byte(x>>16), byte(x>>8), byte(x),
+	)
+}
@@ -0,0 +1,92 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package hasher_test
+
+import (
+	"encoding/binary"
+	"hash"
+	"strings"
+	"testing"
+
+	"github.com/fogfish/it"
+	"github.com/fogfish/ring/hasher"
+)
+
+func sum64(h hash.Hash64, data string) uint64 {
+	h.Write([]byte(data))
+	return h.Sum64()
+}
+
+func TestXXHash64(t *testing.T) {
+	it.Ok(t).
+		If(sum64(hasher.NewXXHash64(), "")).Equal(uint64(0xef46db3751d8e999)).
+		If(sum64(hasher.NewXXHash64(), "a")).Equal(uint64(0xd24ec4f1a98c6e5b)).
+		If(sum64(hasher.NewXXHash64(), "abc")).Equal(uint64(0x44bc2cf5ad770999))
+}
+
+func TestMurmur3(t *testing.T) {
+	h := hasher.NewMurmur3()
+	h.Write([]byte("hello"))
+
+	it.Ok(t).
+		If(sum64(hasher.NewMurmur3(), "")).Equal(uint64(0)).
+		If(h.Sum64()).Equal(uint64(0xcbd8a7b341bd9b02)).
+		If(h.Sum(nil)).Equal([]byte{
+		0xcb, 0xd8, 0xa7, 0xb3, 0x41, 0xbd, 0x9b, 0x02,
+		0x5b, 0x1e, 0x90, 0x6a, 0x48, 0xae, 0x1d, 0x19,
+	})
+}
+
+func TestSipHash(t *testing.T) {
+	msg := make([]byte, 15)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+
+	h := hasher.NewSipHashWithKey(0x0706050403020100, 0x0f0e0d0c0b0a0908)
+	h.Write(msg)
+	it.Ok(t).If(h.Sum64()).Equal(uint64(0xa129ca6149be45e5))
+}
+
+func TestStreaming(t *testing.T) {
+	data := strings.Repeat("One ring to rule them all. ", 10)
+
+	for _, f := range []func() hash.Hash64{
+		hasher.NewXXHash64,
+		hasher.NewMurmur3,
+		hasher.NewSipHash,
+	} {
+		expect := sum64(f(), data)
+		for _, chunk := range []int{1, 3, 7, 13, 31, 33} {
+			h := f()
+			for s := data; len(s) > 0; {
+				n := chunk
+				if n > len(s) {
+					n = len(s)
+				}
+				h.Write([]byte(s[:n]))
+				s = s[n:]
+			}
+			it.Ok(t).
+				If(h.Sum64()).Equal(expect).
+				If(binary.BigEndian.Uint64(h.Sum(nil))).Equal(expect).
+				If(len(h.Sum(nil))).Equal(h.Size())
+		}
+	}
+}
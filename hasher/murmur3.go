@@ -0,0 +1,163 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package hasher
+
+import "hash"
+
+const (
+	murmurC1 uint64 = 0x87c37b91114253d5
+	murmurC2 uint64 = 0x4cf5ad432745937f
+)
+
+// Murmur3 is streaming MurmurHash3 algorithm (x64, 128-bit).
+// Sum64 returns the first half of 128-bit digest.
+type Murmur3 struct {
+	seed   uint64
+	h1, h2 uint64
+	total  uint64
+	mem    [16]byte
+	n      int
+}
+
+var _ hash.Hash64 = (*Murmur3)(nil)
+
+// NewMurmur3 creates Murmur3 with zero seed
+func NewMurmur3() hash.Hash64 { return NewMurmur3WithSeed(0) }
+
+// NewMurmur3WithSeed creates Murmur3 with the seed
+func NewMurmur3WithSeed(seed uint64) hash.Hash64 {
+	h := &Murmur3{seed: seed}
+	h.Reset()
+	return h
+}
+
+// Reset the state of hash
+func (h *Murmur3) Reset() {
+	h.h1, h.h2 = h.seed, h.seed
+	h.total = 0
+	h.n = 0
+}
+
+// Size of digest
+func (h *Murmur3) Size() int { return 16 }
+
+// BlockSize of the algorithm
+func (h *Murmur3) BlockSize() int { return 16 }
+
+// Write data to hash
+func (h *Murmur3) Write(b []byte) (int, error) {
+	size := len(b)
+	h.total += uint64(size)
+
+	if h.n+size < 16 {
+		h.n += copy(h.mem[h.n:], b)
+		return size, nil
+	}
+
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], b)
+		h.h1, h.h2 = murmurBlock(h.h1, h.h2, h.mem[:])
+		b = b[c:]
+		h.n = 0
+	}
+
+	for ; len(b) >= 16; b = b[16:] {
+		h.h1, h.h2 = murmurBlock(h.h1, h.h2, b)
+	}
+
+	h.n = copy(h.mem[:], b)
+	return size, nil
+}
+
+func murmurBlock(h1, h2 uint64, b []byte) (uint64, uint64) {
+	k1, k2 := u64(b[0:]), u64(b[8:])
+
+	h1 ^= murmurK1(k1)
+	h1 = rotl(h1, 27) + h2
+	h1 = h1*5 + 0x52dce729
+
+	h2 ^= murmurK2(k2)
+	h2 = rotl(h2, 31) + h1
+	h2 = h2*5 + 0x38495ab5
+
+	return h1, h2
+}
+
+func murmurK1(k uint64) uint64 { return rotl(k*murmurC1, 31) * murmurC2 }
+
+func murmurK2(k uint64) uint64 { return rotl(k*murmurC2, 33) * murmurC1 }
+
+// Sum appends 128-bit digest to b
+func (h *Murmur3) Sum(b []byte) []byte {
+	h1, h2 := h.sum128()
+	return appendUint64(appendUint64(b, h1), h2)
+}
+
+// Sum64 returns the first half of digest
+func (h *Murmur3) Sum64() uint64 {
+	h1, _ := h.sum128()
+	return h1
+}
+
+func (h *Murmur3) sum128() (uint64, uint64) {
+	h1, h2 := h.h1, h.h2
+
+	var k1, k2 uint64
+	tail := h.mem[:h.n]
+	for i := len(tail) - 1; i >= 8; i-- {
+		k2 = k2<<8 | uint64(tail[i])
+	}
+	for i := min(len(tail), 8) - 1; i >= 0; i-- {
+		k1 = k1<<8 | uint64(tail[i])
+	}
+
+	if len(tail) > 8 {
+		h2 ^= murmurK2(k2)
+	}
+	if len(tail) > 0 {
+		h1 ^= murmurK1(k1)
+	}
+
+	h1 ^= h.total
+	h2 ^= h.total
+	h1 += h2
+	h2 += h1
+	h1 = fmix64(h1)
+	h2 = fmix64(h2)
+	h1 += h2
+	h2 += h1
+
+	return h1, h2
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,134 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package hasher
+
+import "hash"
+
+// SipHash is streaming SipHash-2-4 algorithm keyed by 128-bit key
+type SipHash struct {
+	k0, k1         uint64
+	v0, v1, v2, v3 uint64
+	total          uint64
+	mem            [8]byte
+	n              int
+}
+
+var _ hash.Hash64 = (*SipHash)(nil)
+
+// NewSipHash creates SipHash with zero key
+func NewSipHash() hash.Hash64 { return NewSipHashWithKey(0, 0) }
+
+// NewSipHashWithKey creates SipHash with the key, k0 and k1 are
+// little-endian halves of 128-bit key
+func NewSipHashWithKey(k0, k1 uint64) hash.Hash64 {
+	h := &SipHash{k0: k0, k1: k1}
+	h.Reset()
+	return h
+}
+
+// Reset the state of hash
+func (h *SipHash) Reset() {
+	h.v0 = h.k0 ^ 0x736f6d6570736575
+	h.v1 = h.k1 ^ 0x646f72616e646f6d
+	h.v2 = h.k0 ^ 0x6c7967656e657261
+	h.v3 = h.k1 ^ 0x7465646279746573
+	h.total = 0
+	h.n = 0
+}
+
+// Size of digest
+func (h *SipHash) Size() int { return 8 }
+
+// BlockSize of the algorithm
+func (h *SipHash) BlockSize() int { return 8 }
+
+// Write data to hash
+func (h *SipHash) Write(b []byte) (int, error) {
+	size := len(b)
+	h.total += uint64(size)
+
+	if h.n+size < 8 {
+		h.n += copy(h.mem[h.n:], b)
+		return size, nil
+	}
+
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], b)
+		h.block(u64(h.mem[:]))
+		b = b[c:]
+		h.n = 0
+	}
+
+	for ; len(b) >= 8; b = b[8:] {
+		h.block(u64(b))
+	}
+
+	h.n = copy(h.mem[:], b)
+	return size, nil
+}
+
+func (h *SipHash) block(m uint64) {
+	h.v3 ^= m
+	h.v0, h.v1, h.v2, h.v3 = sipRound(h.v0, h.v1, h.v2, h.v3)
+	h.v0, h.v1, h.v2, h.v3 = sipRound(h.v0, h.v1, h.v2, h.v3)
+	h.v0 ^= m
+}
+
+// Sum appends digest to b
+func (h *SipHash) Sum(b []byte) []byte { return appendUint64(b, h.Sum64()) }
+
+// Sum64 returns digest of data
+func (h *SipHash) Sum64() uint64 {
+	v0, v1, v2, v3 := h.v0, h.v1, h.v2, h.v3
+
+	m := h.total << 56
+	for i := h.n - 1; i >= 0; i-- {
+		m |= uint64(h.mem[i]) << (8 * i)
+	}
+
+	v3 ^= m
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0 ^= m
+
+	v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = rotl(v1, 13)
+	v1 ^= v0
+	v0 = rotl(v0, 32)
+	v2 += v3
+	v3 = rotl(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = rotl(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = rotl(v1, 17)
+	v1 ^= v2
+	v2 = rotl(v2, 32)
+	return v0, v1, v2, v3
+}
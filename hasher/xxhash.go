@@ -0,0 +1,154 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package hasher
+
+import "hash"
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// XXHash64 is streaming xxHash64 algorithm
+type XXHash64 struct {
+	seed           uint64
+	v1, v2, v3, v4 uint64
+	total          uint64
+	mem            [32]byte
+	n              int
+}
+
+var _ hash.Hash64 = (*XXHash64)(nil)
+
+// NewXXHash64 creates xxHash64 with zero seed
+func NewXXHash64() hash.Hash64 { return NewXXHash64WithSeed(0) }
+
+// NewXXHash64WithSeed creates xxHash64 with the seed
+func NewXXHash64WithSeed(seed uint64) hash.Hash64 {
+	h := &XXHash64{seed: seed}
+	h.Reset()
+	return h
+}
+
+// Reset the state of hash
+func (h *XXHash64) Reset() {
+	h.v1 = h.seed + xxPrime1 + xxPrime2
+	h.v2 = h.seed + xxPrime2
+	h.v3 = h.seed
+	h.v4 = h.seed - xxPrime1
+	h.total = 0
+	h.n = 0
+}
+
+// Size of digest
+func (h *XXHash64) Size() int { return 8 }
+
+// BlockSize of the algorithm
+func (h *XXHash64) BlockSize() int { return 32 }
+
+// Write data to hash
+func (h *XXHash64) Write(b []byte) (int, error) {
+	size := len(b)
+	h.total += uint64(size)
+
+	if h.n+size < 32 {
+		h.n += copy(h.mem[h.n:], b)
+		return size, nil
+	}
+
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], b)
+		h.block(h.mem[:])
+		b = b[c:]
+		h.n = 0
+	}
+
+	for ; len(b) >= 32; b = b[32:] {
+		h.block(b)
+	}
+
+	h.n = copy(h.mem[:], b)
+	return size, nil
+}
+
+func (h *XXHash64) block(b []byte) {
+	h.v1 = xxRound(h.v1, u64(b[0:]))
+	h.v2 = xxRound(h.v2, u64(b[8:]))
+	h.v3 = xxRound(h.v3, u64(b[16:]))
+	h.v4 = xxRound(h.v4, u64(b[24:]))
+}
+
+// Sum appends digest to b
+func (h *XXHash64) Sum(b []byte) []byte { return appendUint64(b, h.Sum64()) }
+
+// Sum64 returns digest of data
+func (h *XXHash64) Sum64() uint64 {
+	var x uint64
+
+	if h.total >= 32 {
+		x = rotl(h.v1, 1) + rotl(h.v2, 7) + rotl(h.v3, 12) + rotl(h.v4, 18)
+		x = xxMerge(x, h.v1)
+		x = xxMerge(x, h.v2)
+		x = xxMerge(x, h.v3)
+		x = xxMerge(x, h.v4)
+	} else {
+		x = h.seed + xxPrime5
+	}
+
+	x += h.total
+
+	b := h.mem[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		x ^= xxRound(0, u64(b))
+		x = rotl(x, 27)*xxPrime1 + xxPrime4
+	}
+
+	if len(b) >= 4 {
+		x ^= uint64(u32(b)) * xxPrime1
+		x = rotl(x, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+
+	for _, c := range b {
+		x ^= uint64(c) * xxPrime5
+		x = rotl(x, 11) * xxPrime1
+	}
+
+	x ^= x >> 33
+	x *= xxPrime2
+	x ^= x >> 29
+	x *= xxPrime3
+	x ^= x >> 32
+
+	return x
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = rotl(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
@@ -0,0 +1,63 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import "fmt"
+
+// Hint is the shard whose writes are held by the handoff node
+// on behalf of the unavailable primary node
+type Hint struct {
+	Shard int    // index of the shard
+	Lo    uint64 // lowest address of the shard
+	Hi    uint64 // highest address of the shard
+	Node  string // handoff node holding hinted writes
+	For   string // primary node substituted by the handoff node
+}
+
+func (h Hint) String() string {
+	return fmt.Sprintf("{%d [%x, %x] %s ⇒ %s}", h.Shard, h.Lo, h.Hi, h.Node, h.For)
+}
+
+/*
+
+HintsOf returns shards of the unavailable node substituted by handoff nodes,
+the hinted writes of these shards are replayed to the node when it recovers.
+It is empty unless the node is in handoff or down state. Use the version of
+the ring before the node is recovered, e.g. snapshot of the concurrent ring
+taken before Recover.
+*/
+func (ring *Ring) HintsOf(n uint64, node string) []Hint {
+	seq := make([]Hint, 0)
+	if state := ring.nodes[node].state; state == StateUnknown || state.isPrimary() {
+		return seq
+	}
+
+	primary, handoff := make(Primary, 0, n), make(Handoff, 0, n)
+	for shard := 0; shard < int(ring.q); shard++ {
+		primary, handoff = ring.appendSuccessorOf(primary[:0], handoff[:0], n, shard)
+		for _, hash := range handoff {
+			if hash.hint == node {
+				lo, hi := ring.span(shard)
+				seq = append(seq, Hint{Shard: shard, Lo: lo, Hi: hi, Node: hash.node, For: node})
+			}
+		}
+	}
+
+	return seq
+}
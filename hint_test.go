@@ -0,0 +1,95 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestHint(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	key := randKey()
+	primary, _ := r.SuccessorOf(3, key)
+	r.Handoff(primary[0].Node()).Fail(primary[2].Node())
+
+	seq, handoff := r.SuccessorOf(3, key)
+	it.Ok(t).
+		If(len(seq)).Equal(1).
+		If(seq[0].Hint()).Equal("").
+		If(len(handoff)).Equal(2).
+		If(handoff[0].Hint()).Equal(primary[0].Node()).
+		If(handoff[1].Hint()).Equal(primary[2].Node())
+
+	data, err := json.Marshal(handoff)
+	it.Ok(t).IfNil(err)
+
+	var x Handoff
+	it.Ok(t).
+		IfNil(json.Unmarshal(data, &x)).
+		If(x).Equal(handoff)
+
+	// leaving node is primary, its substitute is not hinted
+	r.Recover(primary[0].Node()).Drain(primary[0].Node())
+	seq, handoff = r.SuccessorOf(3, key)
+	it.Ok(t).
+		If(len(seq)).Equal(2).
+		If(len(handoff)).Equal(2).
+		If(handoff[0].Hint()).Equal("").
+		If(handoff[1].Hint()).Equal(primary[2].Node()).
+		If(len(r.HintsOf(3, primary[0].Node()))).Equal(0)
+}
+
+func TestHintsOf(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	node := r.Members()[0]
+	shards := 0
+	for shard := 0; shard < int(r.q); shard++ {
+		primary, _ := r.successorOf(3, shard)
+		if Hashes(primary).contains(node) {
+			shards++
+		}
+	}
+	it.Ok(t).If(len(r.HintsOf(3, node))).Equal(0)
+
+	r.Handoff(node)
+	hints := r.HintsOf(3, node)
+	it.Ok(t).If(len(hints)).Equal(shards)
+
+	for _, hint := range hints {
+		_, handoff := r.SuccessorOfAddr(3, hint.Lo)
+		it.Ok(t).
+			If(hint.For).Equal(node).
+			IfFalse(hint.Node == node).
+			IfTrue(Hashes(handoff).contains(hint.Node))
+	}
+
+	r.Recover(node)
+	it.Ok(t).If(len(r.HintsOf(3, node))).Equal(0)
+}
@@ -0,0 +1,153 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import "io"
+
+/*
+
+Hashable is the key that writes its binary representation to the hashing
+algorithm. Composite keys are routed without building strings, e.g.
+
+  func (k Key) HashKey(w io.Writer) {
+    io.WriteString(w, k.Tenant)
+    io.WriteString(w, k.ID)
+  }
+
+The key is placed on the ring at the same address as the string of
+concatenated parts unless the ring uses hash tags or the key extractor.
+They are not applied to hashable keys, AddressOf does not co-locate
+the key with tagged string keys. The key shall write the hashed part only
+(e.g. the tag) to be co-located with them. Writer supports io.StringWriter,
+strings are written without memory allocation.
+*/
+type Hashable interface {
+	HashKey(w io.Writer)
+}
+
+// calculate address on the ring for bytes
+func (ring *Ring) addressBytes(key []byte) (int, uint64) {
+	switch {
+	case ring.hashtag:
+		key = hashTag(key)
+	case ring.extract != nil:
+		return ring.address(string(key))
+	}
+
+	d := ring.digest()
+	defer ring.digests.Put(d)
+
+	d.Write(key)
+	return d.address(ring)
+}
+
+// extract hash tag of the key, the content between the first { and
+// the first } after it. The whole key is hashed if tag is empty or missing.
+func hashTag[K string | []byte](key K) K {
+	for i := 0; i < len(key); i++ {
+		if key[i] != '{' {
+			continue
+		}
+
+		for j := i + 1; j < len(key); j++ {
+			if key[j] == '}' {
+				if j == i+1 {
+					return key
+				}
+				return key[i+1 : j]
+			}
+		}
+		return key
+	}
+	return key
+}
+
+// calculate address on the ring for hashable key,
+// hash tags are not applied, the key writes the hashed part itself
+func (ring *Ring) addressOf(key Hashable) (int, uint64) {
+	d := ring.digest()
+	defer ring.digests.Put(d)
+
+	key.HashKey(d)
+	return d.address(ring)
+}
+
+/*
+
+AddressBytes calculates address of key on the ring
+*/
+func (ring *Ring) AddressBytes(key []byte) uint64 {
+	_, addr := ring.addressBytes(key)
+	return addr
+}
+
+/*
+
+AddressOf calculates address of hashable key on the ring.
+Use address to route the key with Lookup, SuccessorOfAddr, After and Before.
+Hash tags and key extractor are not applied, see Hashable.
+*/
+func (ring *Ring) AddressOf(key Hashable) uint64 {
+	_, addr := ring.addressOf(key)
+	return addr
+}
+
+/*
+
+LookupKeyBytes the key position on the ring
+*/
+func (ring *Ring) LookupKeyBytes(key []byte) Node {
+	shard, _ := ring.addressBytes(key)
+	return ring.shards[shard]
+}
+
+/*
+
+SuccessorOfBytes return N distinct nodes to route key. See SuccessorOf
+*/
+func (ring *Ring) SuccessorOfBytes(n uint64, key []byte) (Primary, Handoff) {
+	shard, _ := ring.addressBytes(key)
+	return ring.successorOf(n, shard)
+}
+
+/*
+
+SuccessorOfAddr return N distinct nodes to route address. See SuccessorOf
+*/
+func (ring *Ring) SuccessorOfAddr(n uint64, addr uint64) (Primary, Handoff) {
+	return ring.successorOf(n, ring.shardOf(addr))
+}
+
+/*
+
+AfterKeyBytes returns list of N successors shards for the key.
+*/
+func (ring *Ring) AfterKeyBytes(n uint64, key []byte) []Node {
+	shard, _ := ring.addressBytes(key)
+	return ring.successor(min(n, ring.q), shard)
+}
+
+/*
+
+BeforeKeyBytes returns list of N predecessors shards for the key.
+*/
+func (ring *Ring) BeforeKeyBytes(n uint64, key []byte) []Node {
+	shard, _ := ring.addressBytes(key)
+	return ring.predecessor(min(n, ring.q), shard)
+}
@@ -0,0 +1,138 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"io"
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+type compositeKey struct {
+	tenant, id string
+}
+
+func (k compositeKey) HashKey(w io.Writer) {
+	io.WriteString(w, k.tenant)
+	io.WriteString(w, k.id)
+}
+
+func TestKeyBytes(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	key := randKey()
+	primary, handoff := r.SuccessorOf(3, key)
+	bprimary, bhandoff := r.SuccessorOfBytes(3, []byte(key))
+
+	it.Ok(t).
+		If(r.AddressBytes([]byte(key))).Equal(r.Address(key)).
+		If(r.LookupKeyBytes([]byte(key))).Equal(r.LookupKey(key)).
+		If(bprimary).Equal(primary).
+		If(bhandoff).Equal(handoff).
+		If(r.AfterKeyBytes(3, []byte(key))).Equal(r.AfterKey(3, key)).
+		If(r.BeforeKeyBytes(3, []byte(key))).Equal(r.BeforeKey(3, key))
+}
+
+func TestKeyHashable(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	key := compositeKey{tenant: "tenant", id: randKey()}
+	addr := r.AddressOf(key)
+	primary, handoff := r.SuccessorOf(3, key.tenant+key.id)
+	aprimary, ahandoff := r.SuccessorOfAddr(3, addr)
+
+	it.Ok(t).
+		If(addr).Equal(r.Address(key.tenant + key.id)).
+		If(r.Lookup(addr)).Equal(r.LookupKey(key.tenant + key.id)).
+		If(aprimary).Equal(primary).
+		If(ahandoff).Equal(handoff)
+
+	bkey := []byte(randKey())
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Lookup(r.AddressOf(&key))
+		r.LookupKeyBytes(bkey)
+	})
+	if !raceEnabled {
+		it.Ok(t).If(allocs).Equal(0.0)
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	r := New(M64_Q4096_T256, WithHashTag())
+	p := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+		p.Join(node)
+	}
+
+	primary, _ := r.SuccessorOf(3, "user:{42}:profile")
+	orders, _ := r.SuccessorOf(3, "user:{42}:orders")
+	it.Ok(t).
+		If(r.Address("user:{42}:profile")).Equal(p.Address("42")).
+		If(r.AddressBytes([]byte("user:{42}:profile"))).Equal(p.Address("42")).
+		If(r.LookupKey("user:{42}:profile")).Equal(r.LookupKey("user:{42}:orders")).
+		If(r.AfterKey(2, "user:{42}:profile")).Equal(r.AfterKey(2, "{42}")).
+		If(r.BeforeKey(2, "user:{42}:profile")).Equal(r.BeforeKey(2, "{42}")).
+		If(primary).Equal(orders)
+
+	for key, tag := range map[string]string{
+		"user:{42}:profile": "42",
+		"foo{bar}{zap}":     "bar",
+		"foo{{bar}}zap":     "{bar",
+		"foo{}{bar}":        "foo{}{bar}",
+		"foo{bar":           "foo{bar",
+		"foo":               "foo",
+	} {
+		it.Ok(t).
+			If(hashTag(key)).Equal(tag).
+			If(string(hashTag([]byte(key)))).Equal(tag)
+	}
+
+	data, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+
+	c := New()
+	it.Ok(t).
+		IfNil(c.UnmarshalBinary(data)).
+		If(c.Address("user:{42}:profile")).Equal(p.Address("42"))
+
+	data, err = r.MarshalJSON()
+	it.Ok(t).IfNil(err)
+
+	c = New()
+	it.Ok(t).
+		IfNil(c.UnmarshalJSON(data)).
+		If(c.Address("user:{42}:profile")).Equal(p.Address("42"))
+}
+
+func TestKeyExtractor(t *testing.T) {
+	r := New(WithKeyExtractor(func(key string) string { return key[:2] }))
+	p := New()
+
+	it.Ok(t).
+		If(r.Address("abcd")).Equal(p.Address("ab")).
+		If(r.AddressBytes([]byte("abcd"))).Equal(p.Address("ab"))
+}
@@ -0,0 +1,256 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+/*
+
+Package merkle implements anti-entropy of shards. Replicas build Merkle
+tree of each shard from their keys and versions, exchange trees as digests
+and diff them to find address ranges that need synchronization.
+The shard's address range is divided into 2ᵈ equal leaf buckets,
+trees of replicas are comparable because shards are fixed.
+*/
+package merkle
+
+import (
+	"fmt"
+
+	"github.com/fogfish/ring"
+)
+
+// MaxDepth of the tree, the tree of depth d has 2ᵈ leaves
+const MaxDepth = 20
+
+/*
+
+Tree is Merkle tree of the address range. Leaf is the digest of
+(key, version) pairs whose address falls into the leaf bucket.
+The tree is not safe for concurrent use.
+*/
+type Tree struct {
+	rng   ring.Range
+	depth int
+	width uint64   // address width of the leaf bucket, 0 if the tree has single leaf
+	nodes []uint64 // binary heap of digests, leaves are the tail
+	dirty bool
+}
+
+// New creates empty tree of the address range
+func New(rng ring.Range, depth int) *Tree {
+	if depth < 0 || depth > MaxDepth {
+		panic(fmt.Errorf("merkle: invalid depth %d, expected 0 ≤ depth ≤ %d", depth, MaxDepth))
+	}
+
+	leaves := uint64(1) << depth
+	t := &Tree{
+		rng:   rng,
+		depth: depth,
+		nodes: make([]uint64, 2*leaves-1),
+	}
+	if depth > 0 {
+		t.width = (rng.Hi-rng.Lo)/leaves + 1
+	}
+	return t
+}
+
+// Range of addresses covered by the tree
+func (t *Tree) Range() ring.Range { return t.rng }
+
+// Depth of the tree
+func (t *Tree) Depth() int { return t.depth }
+
+/*
+
+Add (key, version) pair at the address to the tree. Addresses outside of
+the tree's range are ignored. Adding the same pair twice removes it.
+*/
+func (t *Tree) Add(addr uint64, key string, version []byte) {
+	if !t.rng.Contains(addr) {
+		return
+	}
+
+	leaf := len(t.nodes) / 2
+	if t.width != 0 {
+		leaf += int((addr - t.rng.Lo) / t.width)
+	}
+	t.nodes[leaf] ^= digest(key, version)
+	t.dirty = true
+}
+
+// Root digest of the tree
+func (t *Tree) Root() uint64 {
+	t.build()
+	return t.nodes[0]
+}
+
+// Digest of the tree to be exchanged with replicas
+func (t *Tree) Digest() Digest {
+	t.build()
+	return Digest{
+		Range:  t.rng,
+		Depth:  t.depth,
+		Hashes: append([]uint64(nil), t.nodes...),
+	}
+}
+
+// rebuild inner nodes
+func (t *Tree) build() {
+	if !t.dirty {
+		return
+	}
+
+	for i := len(t.nodes)/2 - 1; i >= 0; i-- {
+		t.nodes[i] = combine(t.nodes[2*i+1], t.nodes[2*i+2])
+	}
+	t.dirty = false
+}
+
+/*
+
+Diff returns address ranges where the tree differs from the digest of
+the replica. Ranges are ordered by address, adjacent ranges are merged.
+*/
+func (t *Tree) Diff(other Digest) ([]ring.Range, error) {
+	return Diff(t.Digest(), other)
+}
+
+// Digest is the serializable Merkle tree
+type Digest struct {
+	Range  ring.Range `json:"range"`
+	Depth  int        `json:"depth"`
+	Hashes []uint64   `json:"hashes"`
+}
+
+// Root digest of the tree
+func (d Digest) Root() uint64 {
+	if len(d.Hashes) == 0 {
+		return 0
+	}
+	return d.Hashes[0]
+}
+
+/*
+
+Diff returns address ranges where digests differ. It descends into
+subtrees with different digests only. Digests must be built for the same
+range and depth.
+*/
+func Diff(a, b Digest) ([]ring.Range, error) {
+	if a.Range != b.Range || a.Depth != b.Depth {
+		return nil, fmt.Errorf("merkle: incompatible digests %s/%d and %s/%d",
+			a.Range, a.Depth, b.Range, b.Depth)
+	}
+
+	if a.Depth < 0 || a.Depth > MaxDepth {
+		return nil, fmt.Errorf("merkle: invalid depth %d", a.Depth)
+	}
+
+	size := 2<<a.Depth - 1
+	if len(a.Hashes) != size || len(b.Hashes) != size {
+		return nil, fmt.Errorf("merkle: malformed digest, expected %d hashes", size)
+	}
+
+	t := New(a.Range, a.Depth)
+	seq := make([]ring.Range, 0)
+
+	var walk func(i int)
+	walk = func(i int) {
+		if a.Hashes[i] == b.Hashes[i] {
+			return
+		}
+
+		if 2*i+1 < size {
+			walk(2*i + 1)
+			walk(2*i + 2)
+			return
+		}
+
+		rng, ok := t.leaf(i - size/2)
+		if !ok {
+			return
+		}
+
+		if n := len(seq); n > 0 && seq[n-1].Hi+1 == rng.Lo {
+			seq[n-1].Hi = rng.Hi
+			return
+		}
+		seq = append(seq, rng)
+	}
+	walk(0)
+
+	return seq, nil
+}
+
+// address range of the leaf, trailing leaves of narrow range are empty
+func (t *Tree) leaf(i int) (ring.Range, bool) {
+	if t.width == 0 {
+		return t.rng, true
+	}
+
+	offset := uint64(i) * t.width
+	if offset > t.rng.Hi-t.rng.Lo {
+		return ring.Range{}, false
+	}
+
+	lo := t.rng.Lo + offset
+	hi := t.rng.Hi
+	if t.rng.Hi-lo >= t.width {
+		hi = lo + t.width - 1
+	}
+
+	return ring.Range{Lo: lo, Hi: hi}, true
+}
+
+const (
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// digest of (key, version) pair
+func digest(key string, version []byte) uint64 {
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h = (h ^ uint64(key[i])) * prime64
+	}
+
+	// separator, ("ab", "c") and ("a", "bc") are distinct pairs
+	h = (h ^ 0xff) * prime64
+	for _, x := range version {
+		h = (h ^ uint64(x)) * prime64
+	}
+
+	return mix64(h)
+}
+
+// digest of inner node, empty subtrees have zero digest
+func combine(l, r uint64) uint64 {
+	if l == 0 && r == 0 {
+		return 0
+	}
+
+	return mix64(mix64(l) ^ (r + 0x9e3779b97f4a7c15))
+}
+
+// finalizer of splitmix64
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,189 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package merkle_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/fogfish/it"
+	"github.com/fogfish/ring"
+	"github.com/fogfish/ring/merkle"
+)
+
+func TestTree(t *testing.T) {
+	rng := ring.Range{Lo: 1 << 40, Hi: 1<<41 - 1}
+	a, b := merkle.New(rng, 8), merkle.New(rng, 8)
+
+	for i := uint64(0); i < 1000; i++ {
+		addr := rng.Lo + i*(1<<30)
+		a.Add(addr, fmt.Sprintf("key-%d", i), []byte{1})
+		b.Add(addr, fmt.Sprintf("key-%d", i), []byte{1})
+	}
+	a.Add(0, "outside", []byte{1})
+
+	diff, err := a.Diff(b.Digest())
+	it.Ok(t).
+		IfNil(err).
+		If(a.Root()).Equal(b.Root()).
+		IfFalse(a.Root() == 0).
+		If(len(diff)).Equal(0)
+
+	stale := rng.Lo + 500*(1<<30)
+	b.Add(stale, "key-500", []byte{1})
+	b.Add(stale, "key-500", []byte{2})
+
+	diff, err = a.Diff(b.Digest())
+	it.Ok(t).
+		IfNil(err).
+		IfFalse(a.Root() == b.Root()).
+		If(len(diff)).Equal(1).
+		IfTrue(diff[0].Contains(stale)).
+		If(diff[0].Hi - diff[0].Lo + 1).Equal(uint64(1 << 32))
+
+	b.Add(stale, "key-500", []byte{2})
+	b.Add(stale, "key-500", []byte{1})
+	it.Ok(t).If(a.Root()).Equal(b.Root())
+}
+
+func TestTreeMerge(t *testing.T) {
+	rng := ring.Range{Lo: 0, Hi: 1<<16 - 1}
+	a, b := merkle.New(rng, 4), merkle.New(rng, 4)
+
+	// keys at adjacent leaves [0x1000, 0x2fff] and the last leaf
+	a.Add(0x1000, "a", nil)
+	a.Add(0x2fff, "b", nil)
+	a.Add(0xffff, "c", nil)
+
+	diff, err := merkle.Diff(a.Digest(), b.Digest())
+	it.Ok(t).
+		IfNil(err).
+		If(diff).Equal([]ring.Range{
+		{Lo: 0x1000, Hi: 0x2fff},
+		{Lo: 0xf000, Hi: 0xffff},
+	})
+}
+
+func TestTreeBoundary(t *testing.T) {
+	full := ring.Range{Lo: 0, Hi: math.MaxUint64}
+	a, b := merkle.New(full, 0), merkle.New(full, 0)
+	a.Add(math.MaxUint64, "key", nil)
+
+	diff, err := a.Diff(b.Digest())
+	it.Ok(t).IfNil(err).If(diff).Equal([]ring.Range{full})
+
+	// narrow range has fewer addresses than leaves
+	narrow := ring.Range{Lo: 10, Hi: 12}
+	a, b = merkle.New(narrow, 3), merkle.New(narrow, 3)
+	a.Add(12, "key", nil)
+
+	diff, err = a.Diff(b.Digest())
+	it.Ok(t).IfNil(err).If(diff).Equal([]ring.Range{{Lo: 12, Hi: 12}})
+}
+
+func TestDiffIncompatible(t *testing.T) {
+	a := merkle.New(ring.Range{Lo: 0, Hi: 100}, 2)
+
+	_, err := a.Diff(merkle.New(ring.Range{Lo: 0, Hi: 100}, 3).Digest())
+	it.Ok(t).IfNotNil(err)
+
+	_, err = a.Diff(merkle.New(ring.Range{Lo: 0, Hi: 99}, 2).Digest())
+	it.Ok(t).IfNotNil(err)
+
+	_, err = a.Diff(merkle.Digest{Range: a.Range(), Depth: 64})
+	it.Ok(t).IfNotNil(err)
+}
+
+func TestForest(t *testing.T) {
+	r := ring.New(ring.M64_Q4096_T256)
+	for i := 0; i < 8; i++ {
+		r.Join(fmt.Sprintf("node-%d", i))
+	}
+
+	node := "node-0"
+	plan := merkle.Plan(r, 3, node)
+	it.Ok(t).IfTrue(len(plan) > 0)
+
+	for _, x := range plan {
+		primary, _ := r.SuccessorOfAddr(3, x.Range.Lo)
+		it.Ok(t).If(len(x.Peers)).Equal(len(primary) - 1)
+	}
+
+	keys := func(yield func(string, []byte) bool) {
+		for i := 0; i < 10000; i++ {
+			if !yield(fmt.Sprintf("key-%d", i), []byte("v1")) {
+				return
+			}
+		}
+	}
+
+	// replica of node has lost write of the key
+	key := ""
+	for i := 0; key == ""; i++ {
+		k := fmt.Sprintf("key-%d", i)
+		if primary, _ := r.SuccessorOf(3, k); primary[0].Node() == node {
+			key = k
+		}
+	}
+
+	primary, _ := r.SuccessorOf(3, key)
+	peer := primary[1].Node()
+
+	a := merkle.NewForest(r, 3, node, 6).Build(keys)
+	b := merkle.NewForest(r, 3, peer, 6).Build(keys)
+	b.Add(key, []byte("v1"))
+
+	shard := r.ShardOf(key)
+	digests := b.Digests()
+	for _, x := range plan {
+		if !contains(x.Peers, peer) {
+			continue
+		}
+
+		diff, err := a.Diff(x.Shard, digests[x.Shard])
+		it.Ok(t).IfNil(err)
+
+		if x.Shard != shard {
+			it.Ok(t).If(len(diff)).Equal(0)
+			continue
+		}
+
+		it.Ok(t).
+			If(len(diff)).Equal(1).
+			IfTrue(x.Range.Contains(diff[0].Lo)).
+			IfTrue(diff[0].Contains(r.Address(key)))
+	}
+
+	it.Ok(t).
+		If(len(a.Shards())).Equal(len(plan)).
+		IfNil(a.Tree(-1))
+
+	_, err := a.Diff(-1, digests[shard])
+	it.Ok(t).IfNotNil(err)
+}
+
+func contains(seq []string, x string) bool {
+	for _, s := range seq {
+		if s == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package merkle
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fogfish/ring"
+)
+
+// Seq iterates replica's keys and versions until yield returns false
+type Seq func(yield func(key string, version []byte) bool)
+
+// Exchange is the synchronization of the shard with its replicas
+type Exchange struct {
+	Shard int        // index of the shard
+	Range ring.Range // address range of the shard
+	Peers []string   // replicas of the shard except the node
+}
+
+/*
+
+Plan returns exchanges of shards replicated by the node among N primary
+successors (see SuccessorOf). Exchanges are ordered by shard.
+*/
+func Plan(r *ring.Ring, n uint64, node string) []Exchange {
+	q := len(r.Shards())
+	seq := make([]Exchange, 0)
+
+	for shard := 0; shard < q; shard++ {
+		lo, hi := r.Range(shard)
+		primary, _ := r.SuccessorOfAddr(n, lo)
+
+		peers := make([]string, 0, len(primary))
+		owner := false
+		for _, x := range primary {
+			if x.Node() == node {
+				owner = true
+			} else {
+				peers = append(peers, x.Node())
+			}
+		}
+
+		if owner {
+			seq = append(seq, Exchange{
+				Shard: shard,
+				Range: ring.Range{Lo: lo, Hi: hi},
+				Peers: peers,
+			})
+		}
+	}
+
+	return seq
+}
+
+// Forest of Merkle trees of shards replicated by the node
+type Forest struct {
+	ring  *ring.Ring
+	trees map[int]*Tree
+}
+
+// NewForest creates empty trees of shards replicated by the node, see Plan
+func NewForest(r *ring.Ring, n uint64, node string, depth int) *Forest {
+	f := &Forest{ring: r, trees: map[int]*Tree{}}
+	for _, x := range Plan(r, n, node) {
+		f.trees[x.Shard] = New(x.Range, depth)
+	}
+	return f
+}
+
+// Add (key, version) pair to the tree of its shard,
+// keys of shards that are not replicated by the node are ignored
+func (f *Forest) Add(key string, version []byte) {
+	addr := f.ring.Address(key)
+	if t, exists := f.trees[f.ring.ShardOfAddress(addr)]; exists {
+		t.Add(addr, key, version)
+	}
+}
+
+// Build trees from the replica's keys and versions
+func (f *Forest) Build(seq Seq) *Forest {
+	seq(func(key string, version []byte) bool {
+		f.Add(key, version)
+		return true
+	})
+	return f
+}
+
+// Tree of the shard, nil if the shard is not replicated by the node
+func (f *Forest) Tree(shard int) *Tree {
+	return f.trees[shard]
+}
+
+// Shards replicated by the node, ordered by index
+func (f *Forest) Shards() []int {
+	seq := make([]int, 0, len(f.trees))
+	for shard := range f.trees {
+		seq = append(seq, shard)
+	}
+	sort.Ints(seq)
+	return seq
+}
+
+// Digests of trees by shard
+func (f *Forest) Digests() map[int]Digest {
+	seq := make(map[int]Digest, len(f.trees))
+	for shard, t := range f.trees {
+		seq[shard] = t.Digest()
+	}
+	return seq
+}
+
+/*
+
+Diff returns address ranges of the shard where the node differs from
+the digest of the replica. The shard must be replicated by the node.
+*/
+func (f *Forest) Diff(shard int, other Digest) ([]ring.Range, error) {
+	t, exists := f.trees[shard]
+	if !exists {
+		return nil, fmt.Errorf("merkle: shard %d is not replicated by the node", shard)
+	}
+	return t.Diff(other)
+}
@@ -19,13 +19,81 @@
 package ring
 
 import (
+	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
 	"hash"
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/fogfish/ring/hasher"
 )
 
 // Option for the ring structure
 type Option func(ring *Ring)
 
+// ConfigError is invalid configuration of the ring
+type ConfigError struct {
+	Param  string      // name of invalid param: m, q, t, hash or epsilon
+	Value  interface{} // value of the param
+	Reason string      // the constraint violated by the value
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("ring: invalid %s=%v, %s", e.Param, e.Value, e.Reason)
+}
+
+// validate the ring configuration
+func (ring *Ring) validate() error {
+	if err := validate(ring.m, ring.q); err != nil {
+		return err
+	}
+
+	if ring.t < 1 {
+		return &ConfigError{Param: "t", Value: ring.t, Reason: "expected t ≥ 1"}
+	}
+
+	if ring.hasher == nil {
+		return &ConfigError{Param: "hash", Value: nil, Reason: "hashing algorithm is not defined"}
+	}
+
+	// Note: address is assembled from first m bits of the digest
+	if size := ring.hasher().Size(); uint64(size)*8 < ring.m {
+		return &ConfigError{Param: "hash", Value: size,
+			Reason: fmt.Sprintf("digest of %d bytes is shorter than m=%d bits", size, ring.m)}
+	}
+
+	if !(ring.epsilon >= 0) || math.IsInf(ring.epsilon, 0) {
+		return &ConfigError{Param: "epsilon", Value: ring.epsilon, Reason: "expected finite ε ≥ 0"}
+	}
+
+	return nil
+}
+
+// validate the address space 2^m - 1 split to q shards,
+// each shard covers at least one address
+func validate(m, q uint64) error {
+	if m < 1 || m > 64 {
+		return &ConfigError{Param: "m", Value: m, Reason: "expected 1 ≤ m ≤ 64"}
+	}
+
+	highest := uint64(math.MaxUint64) >> (64 - m)
+	if q < 1 || q-1 > highest {
+		return &ConfigError{Param: "q", Value: q, Reason: fmt.Sprintf("expected 1 ≤ q ≤ 2^%d", m)}
+	}
+
+	// Note: the last shard is truncated if q does not divide 2^m,
+	//       the ring is invalid if shards do not reach the last one
+	if arc := highest/q + 1; (q-1)*arc > highest {
+		return &ConfigError{Param: "q", Value: q, Reason: fmt.Sprintf("shards exceed address space 2^%d", m)}
+	}
+
+	return nil
+}
+
 // WithM8 configures the ring param m=8, so that ring space is 2^m - 1
 func WithM8() Option {
 	return func(ring *Ring) { ring.m = 8 }
@@ -46,8 +114,9 @@ func WithM64() Option {
 	return func(ring *Ring) { ring.m = 64 }
 }
 
-// withM configures the ring param m, number of shards on the ring
-func withM(m uint64) Option {
+// WithM configures the ring param m, so that ring space is 2^m - 1.
+// The param is 1 ≤ m ≤ 64.
+func WithM(m uint64) Option {
 	return func(ring *Ring) { ring.m = m }
 }
 
@@ -66,6 +135,154 @@ func WithHash(f func() hash.Hash) Option {
 	return func(ring *Ring) { ring.hasher = f }
 }
 
+// WithXXHash64 configures xxHash64 hashing algorithm for the ring
+func WithXXHash64() Option {
+	return WithHash(newXXHash64)
+}
+
+// WithFNV1a configures 64-bit FNV-1a hashing algorithm for the ring
+func WithFNV1a() Option {
+	return WithHash(newFNV1a)
+}
+
+// WithMurmur3 configures Murmur3 (x64, 128-bit) hashing algorithm for the ring
+func WithMurmur3() Option {
+	return WithHash(newMurmur3)
+}
+
+// WithSipHash configures SipHash-2-4 hashing algorithm keyed by 128-bit key
+// for the ring. The keyed algorithm has to be registered (see RegisterHash)
+// to serialize the ring, SipHash with zero key is registered as "siphash".
+func WithSipHash(k0, k1 uint64) Option {
+	return WithHash(func() hash.Hash { return hasher.NewSipHashWithKey(k0, k1) })
+}
+
+func newXXHash64() hash.Hash { return hasher.NewXXHash64() }
+func newFNV1a() hash.Hash    { return fnv.New64a() }
+func newMurmur3() hash.Hash  { return hasher.NewMurmur3() }
+func newSipHash() hash.Hash  { return hasher.NewSipHash() }
+
+// RegisterHash makes hashing algorithm known to the ring under the name.
+// The name identifies the algorithm when the ring is serialized.
+// It is safe for concurrent use.
+func RegisterHash(name string, f func() hash.Hash) {
+	hashersLock.Lock()
+	defer hashersLock.Unlock()
+
+	for i, x := range hashers {
+		if x.name == name {
+			hashers[i].hasher = f
+			return
+		}
+	}
+	hashers = append(hashers, namedHash{name: name, hasher: f})
+}
+
+// registry of known hashing algorithms
+type namedHash struct {
+	name   string
+	hasher func() hash.Hash
+}
+
+var hashersLock sync.RWMutex
+
+var hashers = []namedHash{
+	{name: "sha1", hasher: sha1.New},
+	{name: "sha256", hasher: sha256.New},
+	{name: "sha512", hasher: sha512.New},
+	{name: "md5", hasher: md5.New},
+	{name: "xxhash64", hasher: newXXHash64},
+	{name: "fnv1a", hasher: newFNV1a},
+	{name: "murmur3", hasher: newMurmur3},
+	{name: "siphash", hasher: newSipHash},
+}
+
+// lookup hashing algorithm by name
+func hashByName(name string) func() hash.Hash {
+	hashersLock.RLock()
+	defer hashersLock.RUnlock()
+
+	for _, x := range hashers {
+		if x.name == name {
+			return x.hasher
+		}
+	}
+	return nil
+}
+
+// lookup name of hashing algorithm, functions are not comparable
+// therefore algorithms are matched by the digest of probe value
+func hashName(f func() hash.Hash) string {
+	probe := func(f func() hash.Hash) string {
+		h := f()
+		h.Write([]byte("One ring to rule them all"))
+		return string(h.Sum(nil))
+	}
+
+	digest := probe(f)
+
+	hashersLock.RLock()
+	defer hashersLock.RUnlock()
+
+	for _, x := range hashers {
+		if probe(x.hasher) == digest {
+			return x.name
+		}
+	}
+	return ""
+}
+
+// WithZoneAware configures zone aware placement of replicas. Successors of
+// the key are picked from distinct zones first, falling back to distinct nodes.
+func WithZoneAware() Option {
+	return func(ring *Ring) { ring.zoned = true }
+}
+
+// WithBoundedLoad configures consistent hashing with bounded loads.
+// The load of node does not exceed (1+ε) times the average load.
+func WithBoundedLoad(epsilon float64) Option {
+	return func(ring *Ring) { ring.epsilon = epsilon }
+}
+
+/*
+
+WithHashTag configures Redis-style hash tags. If the key contains {...}
+only the content between the first { and the first } after it is hashed,
+so that keys user:{42}:profile and user:{42}:orders are placed on the same shard.
+The whole key is hashed if the tag is missing or empty.
+*/
+func WithHashTag() Option {
+	return func(ring *Ring) {
+		ring.hashtag = true
+		ring.extract = nil
+	}
+}
+
+/*
+
+WithKeyExtractor configures the function that extracts the hashed part of key.
+The function is not serialized with the ring, configure it on the instance
+before it is unmarshalled.
+*/
+func WithKeyExtractor(f func(key string) string) Option {
+	return func(ring *Ring) {
+		ring.hashtag = false
+		ring.extract = f
+	}
+}
+
+/*
+
+WithPreferenceList configures index of preference lists for replication
+factors N. SuccessorOf with indexed N is a table lookup, the index is
+rebuilt on each topology change. It requires memory proportional to Q·N.
+*/
+func WithPreferenceList(n ...uint64) Option {
+	return func(ring *Ring) {
+		ring.replicas = append([]uint64(nil), n...)
+	}
+}
+
 // WithRing clones ring configuration into the new instance
 func WithRing(r *Ring) Option {
 	return func(ring *Ring) {
@@ -73,6 +290,11 @@ func WithRing(r *Ring) Option {
 		ring.q = r.q
 		ring.t = r.t
 		ring.hasher = r.hasher
+		ring.zoned = r.zoned
+		ring.epsilon = r.epsilon
+		ring.hashtag = r.hashtag
+		ring.extract = r.extract
+		ring.replicas = r.replicas
 	}
 }
 
@@ -0,0 +1,99 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package partition
+
+/*
+
+Jump is the jump consistent hash (Lamping, Veach 2014). Nodes are buckets
+numbered in the order of join. The algorithm is optimal when nodes join
+and leave at the tail, leaving node from the middle shifts buckets.
+*/
+type Jump struct {
+	nodes nodes
+}
+
+var _ Partitioner = (*Jump)(nil)
+
+// NewJump creates jump consistent hash partitioner
+func NewJump() *Jump {
+	return &Jump{}
+}
+
+// Join node to the partitioner
+func (p *Jump) Join(node string) {
+	if !p.nodes.contains(node) {
+		p.nodes = append(p.nodes, node)
+	}
+}
+
+// Leave node from the partitioner
+func (p *Jump) Leave(node string) {
+	p.nodes = p.nodes.without(node)
+}
+
+// Lookup the node responsible for the key
+func (p *Jump) Lookup(key string) string {
+	if len(p.nodes) == 0 {
+		return ""
+	}
+
+	return p.nodes[jump(hash64(key, 0), len(p.nodes))]
+}
+
+// SuccessorOf returns N distinct nodes responsible for the key.
+// Successors are buckets of the key hashed with distinct seeds.
+func (p *Jump) SuccessorOf(n uint64, key string) []string {
+	size := int(min(n, uint64(len(p.nodes))))
+	seq := make(nodes, 0, size)
+
+	for seed := uint64(0); len(seq) < size && seed < 4*uint64(size); seed++ {
+		node := p.nodes[jump(hash64(key, seed), len(p.nodes))]
+		if !seq.contains(node) {
+			seq = append(seq, node)
+		}
+	}
+
+	// Note: fallback to next buckets if seeds have not given enough nodes
+	for i := 0; len(seq) < size; i++ {
+		node := p.nodes[i]
+		if !seq.contains(node) {
+			seq = append(seq, node)
+		}
+	}
+
+	return seq
+}
+
+// jump consistent hash function
+func jump(key uint64, buckets int) int {
+	b, j := int64(-1), int64(0)
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
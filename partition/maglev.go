@@ -0,0 +1,147 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package partition
+
+import (
+	"fmt"
+	"math/big"
+)
+
+/*
+
+Maglev is the consistent hashing of Google's Maglev load balancer
+(Eisenbud et al. 2016). Each node fills the lookup table using its own
+permutation of table slots, the table is rebuilt when nodes join or leave.
+*/
+type Maglev struct {
+	m     uint64
+	nodes nodes
+	table []int
+}
+
+var _ Partitioner = (*Maglev)(nil)
+
+// MaglevTableSize is default size of the lookup table, it is a prime number
+const MaglevTableSize = 65537
+
+// NewMaglev creates Maglev partitioner with lookup table of given size,
+// it panics if the size is invalid. See NewMaglevE for error-returning variant.
+func NewMaglev(size uint64) *Maglev {
+	p, err := NewMaglevE(size)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// NewMaglevE creates Maglev partitioner with lookup table of given size,
+// the default size is used if the size is 0. The size shall be prime number
+// much larger than number of nodes, otherwise permutations of nodes do not
+// cover the table.
+func NewMaglevE(size uint64) (*Maglev, error) {
+	if size == 0 {
+		size = MaglevTableSize
+	}
+
+	if !new(big.Int).SetUint64(size).ProbablyPrime(0) {
+		return nil, fmt.Errorf("partition: maglev table size %d is not prime", size)
+	}
+
+	return &Maglev{m: size}, nil
+}
+
+// Join node to the partitioner
+func (p *Maglev) Join(node string) {
+	if !p.nodes.contains(node) {
+		p.nodes = append(p.nodes, node)
+		p.populate()
+	}
+}
+
+// Leave node from the partitioner
+func (p *Maglev) Leave(node string) {
+	if p.nodes.contains(node) {
+		p.nodes = p.nodes.without(node)
+		p.populate()
+	}
+}
+
+// Lookup the node responsible for the key
+func (p *Maglev) Lookup(key string) string {
+	if len(p.nodes) == 0 {
+		return ""
+	}
+
+	return p.nodes[p.table[hash64(key, 0)%p.m]]
+}
+
+// SuccessorOf returns N distinct nodes responsible for the key,
+// the lookup table is walked from the key's slot
+func (p *Maglev) SuccessorOf(n uint64, key string) []string {
+	size := int(min(n, uint64(len(p.nodes))))
+	seq := make(nodes, 0, size)
+
+	slot := hash64(key, 0) % p.m
+	for i := uint64(0); len(seq) < size && i < p.m; i++ {
+		node := p.nodes[p.table[(slot+i)%p.m]]
+		if !seq.contains(node) {
+			seq = append(seq, node)
+		}
+	}
+
+	return seq
+}
+
+// populate the lookup table
+func (p *Maglev) populate() {
+	if len(p.nodes) == 0 {
+		p.table = nil
+		return
+	}
+
+	offset := make([]uint64, len(p.nodes))
+	skip := make([]uint64, len(p.nodes))
+	for i, node := range p.nodes {
+		offset[i] = hash64(node, 1) % p.m
+		skip[i] = hash64(node, 2)%(p.m-1) + 1
+	}
+
+	table := make([]int, p.m)
+	for i := range table {
+		table[i] = -1
+	}
+
+	next := make([]uint64, len(p.nodes))
+	for filled := uint64(0); ; {
+		for i := range p.nodes {
+			slot := (offset[i] + next[i]*skip[i]) % p.m
+			for table[slot] >= 0 {
+				next[i]++
+				slot = (offset[i] + next[i]*skip[i]) % p.m
+			}
+			table[slot] = i
+			next[i]++
+
+			if filled++; filled == p.m {
+				p.table = table
+				return
+			}
+		}
+	}
+}
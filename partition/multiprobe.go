@@ -0,0 +1,122 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package partition
+
+import "sort"
+
+/*
+
+MultiProbe is the multi-probe consistent hashing (Appleton, O'Reilly 2015).
+Each node is a single point on the ring, the key is hashed K times and
+the node closest to one of probes owns the key.
+*/
+type MultiProbe struct {
+	k      uint64
+	points []point
+}
+
+var _ Partitioner = (*MultiProbe)(nil)
+
+type point struct {
+	addr uint64
+	node string
+}
+
+// MultiProbeDefault is default number of probes, it gives
+// peak-to-mean ratio of 1.05
+const MultiProbeDefault = 21
+
+// NewMultiProbe creates multi-probe partitioner with K probes
+func NewMultiProbe(k uint64) *MultiProbe {
+	if k == 0 {
+		k = MultiProbeDefault
+	}
+	return &MultiProbe{k: k}
+}
+
+// Join node to the partitioner
+func (p *MultiProbe) Join(node string) {
+	if p.indexOf(node) != -1 {
+		return
+	}
+
+	p.points = append(p.points, point{addr: hash64(node, 0), node: node})
+	sort.Slice(p.points, func(i, j int) bool { return p.points[i].addr < p.points[j].addr })
+}
+
+// Leave node from the partitioner
+func (p *MultiProbe) Leave(node string) {
+	if i := p.indexOf(node); i != -1 {
+		p.points = append(p.points[:i:i], p.points[i+1:]...)
+	}
+}
+
+func (p *MultiProbe) indexOf(node string) int {
+	for i, x := range p.points {
+		if x.node == node {
+			return i
+		}
+	}
+	return -1
+}
+
+// Lookup the node responsible for the key
+func (p *MultiProbe) Lookup(key string) string {
+	if len(p.points) == 0 {
+		return ""
+	}
+
+	node, distance := 0, uint64(0)
+	for i := uint64(0); i < p.k; i++ {
+		addr := hash64(key, i)
+		at := p.successor(addr)
+		if d := p.points[at].addr - addr; i == 0 || d < distance {
+			node, distance = at, d
+		}
+	}
+
+	return p.points[node].node
+}
+
+// SuccessorOf returns N distinct nodes responsible for the key.
+// The first node is the closest to one of probes, other nodes
+// follow it on the ring.
+func (p *MultiProbe) SuccessorOf(n uint64, key string) []string {
+	size := int(min(n, uint64(len(p.points))))
+	seq := make([]string, 0, size)
+	if size == 0 {
+		return seq
+	}
+
+	at := p.indexOf(p.Lookup(key))
+	for i := 0; i < size; i++ {
+		seq = append(seq, p.points[(at+i)%len(p.points)].node)
+	}
+
+	return seq
+}
+
+// index of first point at or after the address, wraps around the ring
+func (p *MultiProbe) successor(addr uint64) int {
+	i := sort.Search(len(p.points), func(i int) bool { return p.points[i].addr >= addr })
+	if i == len(p.points) {
+		return 0
+	}
+	return i
+}
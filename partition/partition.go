@@ -0,0 +1,103 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+/*
+
+Package partition defines common interface of partitioning strategies,
+so that applications switch strategies without touching call sites.
+The consistent hashing ring is one of the strategies, others are
+jump consistent hash, rendezvous (HRW) hashing, Maglev and
+multi-probe consistent hashing.
+*/
+package partition
+
+// Partitioner distributes keys across nodes
+type Partitioner interface {
+	// Join node to the partitioner
+	Join(node string)
+
+	// Leave node from the partitioner
+	Leave(node string)
+
+	// Lookup the node responsible for the key
+	Lookup(key string) string
+
+	// SuccessorOf returns N distinct nodes responsible for the key,
+	// the first node is the one returned by Lookup
+	SuccessorOf(n uint64, key string) []string
+}
+
+//------------------------------------------------------------------------------
+//
+// hashing
+//
+//------------------------------------------------------------------------------
+
+const (
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// hash the string with seed, FNV-1a followed by 64-bit finalizer
+func hash64(s string, seed uint64) uint64 {
+	h := uint64(offset64) ^ mix64(seed)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return mix64(h)
+}
+
+// finalizer of splitmix64, it gives avalanche effect to the hash
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// list of nodes
+type nodes []string
+
+func (seq nodes) contains(node string) bool {
+	for _, x := range seq {
+		if x == node {
+			return true
+		}
+	}
+	return false
+}
+
+func (seq nodes) indexOf(node string) int {
+	for i, x := range seq {
+		if x == node {
+			return i
+		}
+	}
+	return -1
+}
+
+func (seq nodes) without(node string) nodes {
+	i := seq.indexOf(node)
+	if i == -1 {
+		return seq
+	}
+	return append(seq[:i:i], seq[i+1:]...)
+}
@@ -0,0 +1,174 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package partition_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fogfish/it"
+	"github.com/fogfish/ring"
+	"github.com/fogfish/ring/partition"
+)
+
+var strategies = []struct {
+	name string
+	new  func() partition.Partitioner
+}{
+	{"ring", func() partition.Partitioner { return partition.NewRing(ring.M64_Q4096_T256) }},
+	{"jump", func() partition.Partitioner { return partition.NewJump() }},
+	{"rendezvous", func() partition.Partitioner { return partition.NewRendezvous() }},
+	{"maglev", func() partition.Partitioner { return partition.NewMaglev(0) }},
+	{"multiprobe", func() partition.Partitioner { return partition.NewMultiProbe(0) }},
+}
+
+func nodes(n int) []string {
+	seq := make([]string, n)
+	for i := range seq {
+		seq[i] = fmt.Sprintf("10.0.0.%d", i)
+	}
+	return seq
+}
+
+func keys(n int) []string {
+	seq := make([]string, n)
+	for i := range seq {
+		seq[i] = fmt.Sprintf("key-%d", i)
+	}
+	return seq
+}
+
+func TestPartitioner(t *testing.T) {
+	for _, s := range strategies {
+		t.Run(s.name, func(t *testing.T) {
+			p := s.new()
+			it.Ok(t).If(p.Lookup("key")).Equal("")
+
+			for _, node := range nodes(8) {
+				p.Join(node)
+			}
+
+			t.Run("Deterministic", func(t *testing.T) {
+				q := s.new()
+				for _, node := range nodes(8) {
+					q.Join(node)
+				}
+
+				for _, key := range keys(100) {
+					it.Ok(t).If(p.Lookup(key)).Equal(q.Lookup(key))
+				}
+			})
+
+			t.Run("Balance", func(t *testing.T) {
+				load := map[string]int{}
+				for _, key := range keys(8000) {
+					load[p.Lookup(key)]++
+				}
+
+				it.Ok(t).If(len(load)).Equal(8)
+				for _, x := range load {
+					it.Ok(t).IfTrue(x > 700 && x < 1300)
+				}
+			})
+
+			t.Run("SuccessorOf", func(t *testing.T) {
+				for _, key := range keys(100) {
+					seq := p.SuccessorOf(3, key)
+					it.Ok(t).
+						If(len(seq)).Equal(3).
+						If(seq[0]).Equal(p.Lookup(key)).
+						IfFalse(seq[0] == seq[1] || seq[1] == seq[2] || seq[0] == seq[2])
+				}
+
+				it.Ok(t).If(len(p.SuccessorOf(20, "key"))).Equal(8)
+			})
+
+			t.Run("Leave", func(t *testing.T) {
+				node := nodes(8)[7]
+				before := map[string]string{}
+				for _, key := range keys(1000) {
+					before[key] = p.Lookup(key)
+				}
+
+				// Note: Maglev gives minimal but not zero disruption
+				moved := 0
+				p.Leave(node)
+				for _, key := range keys(1000) {
+					after := p.Lookup(key)
+					it.Ok(t).IfFalse(after == node)
+					if before[key] != node && after != before[key] {
+						moved++
+					}
+				}
+				it.Ok(t).IfTrue(moved < 20)
+			})
+		})
+	}
+}
+
+func TestMaglevTableSize(t *testing.T) {
+	for _, size := range []uint64{1, 4, 100, 65536} {
+		_, err := partition.NewMaglevE(size)
+		it.Ok(t).IfNotNil(err)
+	}
+
+	// prime table smaller than number of nodes is filled
+	p, err := partition.NewMaglevE(7)
+	it.Ok(t).IfNil(err)
+	for _, node := range nodes(20) {
+		p.Join(node)
+	}
+	it.Ok(t).
+		IfFalse(p.Lookup("key") == "").
+		If(len(p.SuccessorOf(3, "key"))).Equal(3)
+}
+
+func BenchmarkLookup(b *testing.B) {
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			p := s.new()
+			for _, node := range nodes(100) {
+				p.Join(node)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.Lookup("key-lookup")
+			}
+		})
+	}
+}
+
+func BenchmarkSuccessorOf(b *testing.B) {
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			p := s.new()
+			for _, node := range nodes(100) {
+				p.Join(node)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.SuccessorOf(3, "key-lookup")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package partition
+
+import "sort"
+
+/*
+
+Rendezvous is the highest random weight (HRW) hashing. Each node scores
+the key, the node with highest score owns the key.
+*/
+type Rendezvous struct {
+	nodes  nodes
+	hashes []uint64
+}
+
+var _ Partitioner = (*Rendezvous)(nil)
+
+// NewRendezvous creates rendezvous hashing partitioner
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{}
+}
+
+// Join node to the partitioner
+func (p *Rendezvous) Join(node string) {
+	if !p.nodes.contains(node) {
+		p.nodes = append(p.nodes, node)
+		p.hashes = append(p.hashes, hash64(node, 0))
+	}
+}
+
+// Leave node from the partitioner
+func (p *Rendezvous) Leave(node string) {
+	i := p.nodes.indexOf(node)
+	if i == -1 {
+		return
+	}
+
+	p.nodes = append(p.nodes[:i:i], p.nodes[i+1:]...)
+	p.hashes = append(p.hashes[:i:i], p.hashes[i+1:]...)
+}
+
+// Lookup the node responsible for the key
+func (p *Rendezvous) Lookup(key string) string {
+	hkey := hash64(key, 0)
+
+	node, score := "", uint64(0)
+	for i, h := range p.hashes {
+		if s := mix64(hkey ^ h); node == "" || s > score {
+			node, score = p.nodes[i], s
+		}
+	}
+	return node
+}
+
+// SuccessorOf returns N nodes with highest score for the key
+func (p *Rendezvous) SuccessorOf(n uint64, key string) []string {
+	hkey := hash64(key, 0)
+
+	type scored struct {
+		node  string
+		score uint64
+	}
+
+	seq := make([]scored, len(p.nodes))
+	for i, h := range p.hashes {
+		seq[i] = scored{node: p.nodes[i], score: mix64(hkey ^ h)}
+	}
+	sort.Slice(seq, func(i, j int) bool { return seq[i].score > seq[j].score })
+
+	size := int(min(n, uint64(len(seq))))
+	successors := make([]string, size)
+	for i := range successors {
+		successors[i] = seq[i].node
+	}
+	return successors
+}
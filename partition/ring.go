@@ -0,0 +1,66 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package partition
+
+import "github.com/fogfish/ring"
+
+// Ring is the partitioner built on consistent hashing ring
+type Ring struct {
+	ring *ring.Ring
+}
+
+var _ Partitioner = (*Ring)(nil)
+
+// NewRing creates the partitioner using consistent hashing ring
+func NewRing(opts ...ring.Option) *Ring {
+	return &Ring{ring: ring.New(opts...)}
+}
+
+// FromRing creates the partitioner from existing ring
+func FromRing(r *ring.Ring) *Ring {
+	return &Ring{ring: r}
+}
+
+// Ring returns the underlying ring
+func (p *Ring) Ring() *ring.Ring { return p.ring }
+
+// Join node to the ring
+func (p *Ring) Join(node string) { p.ring.Join(node) }
+
+// Leave node from the ring
+func (p *Ring) Leave(node string) { p.ring.Leave(node) }
+
+// Lookup the node responsible for the key
+func (p *Ring) Lookup(key string) string {
+	return p.ring.LookupKey(key).Node()
+}
+
+// SuccessorOf returns N distinct nodes, primary nodes are followed by handoff
+func (p *Ring) SuccessorOf(n uint64, key string) []string {
+	primary, handoff := p.ring.SuccessorOf(n, key)
+
+	seq := make([]string, 0, len(primary)+len(handoff))
+	for _, x := range primary {
+		seq = append(seq, x.Node())
+	}
+	for _, x := range handoff {
+		seq = append(seq, x.Node())
+	}
+	return seq
+}
@@ -0,0 +1,110 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import "sync"
+
+// index of preference lists for configured replication factors. The index
+// is built once on first use after the topology change, it is immutable
+// so that copies of the ring share it.
+type preferences struct {
+	once  sync.Once
+	lists map[uint64]*preferenceList
+}
+
+// preference lists of shards for replication factor N,
+// lists are slices of the shared buffer
+type preferenceList struct {
+	buf   Hashes
+	index []preference
+}
+
+// preference list of the shard: buf[lo:mid] is primary and buf[mid:hi] is handoff
+type preference struct {
+	lo, mid, hi int
+}
+
+// invalidate preference lists on topology change
+func (ring *Ring) prefer() {
+	if len(ring.replicas) == 0 {
+		ring.prefs = nil
+		return
+	}
+
+	ring.prefs = &preferences{}
+}
+
+// build preference lists if they are invalidated
+func (ring *Ring) preferences() *preferences {
+	prefs := ring.prefs
+	if prefs == nil {
+		return nil
+	}
+
+	prefs.once.Do(func() {
+		prefs.lists = make(map[uint64]*preferenceList, len(ring.replicas))
+		for _, n := range ring.replicas {
+			prefs.lists[n] = ring.preferenceList(n)
+		}
+	})
+
+	return prefs
+}
+
+func (ring *Ring) preferenceList(n uint64) *preferenceList {
+	list := &preferenceList{
+		buf:   make(Hashes, 0, ring.q*n),
+		index: make([]preference, ring.q),
+	}
+
+	primary, handoff := make(Primary, 0, n), make(Handoff, 0, n)
+	for shard := range list.index {
+		primary, handoff = ring.walkSuccessorOf(primary[:0], handoff[:0], n, shard)
+
+		lo := len(list.buf)
+		list.buf = append(list.buf, primary...)
+		mid := len(list.buf)
+		list.buf = append(list.buf, handoff...)
+		list.index[shard] = preference{lo: lo, mid: mid, hi: len(list.buf)}
+	}
+
+	return list
+}
+
+// appends preference list of the shard, it returns false if the list
+// is not indexed for replication factor N
+func (ring *Ring) preferred(primary Primary, handoff Handoff, n uint64, shard int) (Primary, Handoff, bool) {
+	prefs := ring.preferences()
+	if prefs == nil {
+		return primary, handoff, false
+	}
+
+	list, exists := prefs.lists[n]
+	if !exists {
+		return primary, handoff, false
+	}
+
+	p := list.index[shard]
+	primary = append(primary, list.buf[p.lo:p.mid]...)
+	if p.hi > p.mid {
+		handoff = append(handoff, list.buf[p.mid:p.hi]...)
+	}
+
+	return primary, handoff, true
+}
@@ -0,0 +1,102 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestPreferenceList(t *testing.T) {
+	r := New(M64_Q4096_T256, WithPreferenceList(1, 3))
+	p := New(M64_Q4096_T256)
+
+	check := func() {
+		for _, key := range randKeys(200) {
+			for _, n := range []uint64{1, 2, 3} {
+				rprimary, rhandoff := r.SuccessorOf(n, key)
+				pprimary, phandoff := p.SuccessorOf(n, key)
+				it.Ok(t).
+					If(rprimary).Equal(pprimary).
+					If(rhandoff).Equal(phandoff)
+			}
+		}
+	}
+
+	nodes := randKeys(8)
+	for _, node := range nodes {
+		r.Join(node)
+		p.Join(node)
+	}
+	check()
+
+	r.Handoff(nodes[0]).Bootstrap("joining").Fail(nodes[1])
+	p.Handoff(nodes[0]).Bootstrap("joining").Fail(nodes[1])
+	check()
+
+	r.Leave(nodes[2]).JoinWithWeight(nodes[3], 2.0).Drain(nodes[4])
+	p.Leave(nodes[2]).JoinWithWeight(nodes[3], 2.0).Drain(nodes[4])
+	check()
+
+	data, err := p.MarshalBinary()
+	it.Ok(t).IfNil(err)
+
+	r = New(WithPreferenceList(1, 3))
+	it.Ok(t).IfNil(r.UnmarshalBinary(data))
+	check()
+}
+
+func TestPreferenceListConcurrent(t *testing.T) {
+	c := NewConcurrent(M64_Q4096_T256, WithPreferenceList(3))
+	for _, node := range randKeys(8) {
+		c.Join(node)
+	}
+
+	primary, handoff := make(Primary, 0, 3), make(Handoff, 0, 3)
+	allocs := testing.AllocsPerRun(100, func() {
+		primary, handoff = c.AppendSuccessorOf(primary[:0], handoff[:0], 3, "key")
+	})
+
+	it.Ok(t).If(len(primary)).Equal(3)
+	if !raceEnabled {
+		it.Ok(t).If(allocs).Equal(0.0)
+	}
+}
+
+func BenchmarkPreferenceList(b *testing.B) {
+	for name, opts := range map[string][]Option{
+		"walk":  {WithQ(65536), WithT(256)},
+		"index": {WithQ(65536), WithT(256), WithPreferenceList(3)},
+	} {
+		b.Run(name, func(b *testing.B) {
+			r := New(opts...)
+			for i := 0; i < 300; i++ {
+				r.Join(randKey())
+			}
+			r.SuccessorOf(3, "key")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				r.SuccessorOf(3, "key")
+			}
+		})
+	}
+}
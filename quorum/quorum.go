@@ -0,0 +1,250 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+/*
+
+Package quorum implements R/W quorum on top of the ring's preference list.
+The executor fans out the operation to N replicas of the key returned by
+SuccessorOf, handoff nodes substitute unavailable primaries (sloppy quorum).
+It returns once R (W) replicas have responded successfully or the quorum
+is not reachable anymore. Responses of quorum read are reconciled by
+ReadRepair, which writes the latest version to stale replicas.
+*/
+package quorum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fogfish/ring"
+)
+
+// Router provides the ring to route the operation, replicas and their states
+// are resolved from one snapshot. It is implemented by ring.Concurrent.
+type Router interface {
+	Snapshot() *ring.Ring
+}
+
+var _ Router = (*ring.Concurrent)(nil)
+
+// Static routes operations using the ring, the ring must not be modified
+// while operations are routed
+func Static(r *ring.Ring) Router { return static{r} }
+
+type static struct{ ring *ring.Ring }
+
+func (s static) Snapshot() *ring.Ring { return s.ring }
+
+// Executor of quorum reads and writes
+type Executor struct {
+	router  Router
+	n, r, w int
+}
+
+/*
+
+New creates quorum executor of N replicas, R and W are number of replicas
+required for successful read and write. It returns error unless 1 ≤ R, W ≤ N.
+*/
+func New(router Router, n, r, w int) (*Executor, error) {
+	switch {
+	case n < 1:
+		return nil, fmt.Errorf("quorum: invalid N = %d, expected N ≥ 1", n)
+	case r < 1 || r > n:
+		return nil, fmt.Errorf("quorum: invalid R = %d, expected 1 ≤ R ≤ %d", r, n)
+	case w < 1 || w > n:
+		return nil, fmt.Errorf("quorum: invalid W = %d, expected 1 ≤ W ≤ %d", w, n)
+	}
+
+	return &Executor{router: router, n: n, r: r, w: w}, nil
+}
+
+// Response of the replica
+type Response[T any] struct {
+	Node  ring.Node
+	Value T
+	Err   error
+}
+
+// Error is aggregated failure of the quorum
+type Error struct {
+	Op       string  // operation, read or write
+	Key      string  // key of the operation
+	Required int     // number of replicas required by quorum
+	Success  int     // number of replicas succeeded
+	Errors   []error // failures of replicas
+}
+
+func (e *Error) Error() string {
+	seq := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		seq[i] = err.Error()
+	}
+
+	return fmt.Sprintf("quorum: %s %s failed, %d of %d replicas succeeded: [%s]",
+		e.Op, e.Key, e.Success, e.Required, strings.Join(seq, "; "))
+}
+
+// Is reports whether any failure of replicas matches the target, see errors.Is
+func (e *Error) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first failure of replicas that matches the target, see errors.As
+func (e *Error) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+
+Targets returns replicas of the key for reads or writes. Joining nodes
+receive writes only, leaving nodes serve reads only. Handoff nodes
+substitute primaries that are unavailable for the operation.
+*/
+func (e *Executor) Targets(key string, write bool) []ring.Node {
+	snapshot := e.router.Snapshot()
+	primary, handoff := snapshot.SuccessorOf(uint64(e.n), key)
+
+	seq := make([]ring.Node, 0, len(primary)+len(handoff))
+	skipped := 0
+	for _, node := range primary {
+		if serves(snapshot.State(node.Node()), write) {
+			seq = append(seq, node)
+		} else {
+			skipped++
+		}
+	}
+
+	// hinted handoff nodes substitute unavailable nodes, others substitute
+	// joining or leaving primaries for the operation they do not serve
+	for _, node := range handoff {
+		switch {
+		case node.Hint() != "":
+			seq = append(seq, node)
+		case skipped > 0:
+			seq = append(seq, node)
+			skipped--
+		}
+	}
+
+	return seq
+}
+
+// primary in the state serves the operation itself
+func serves(state ring.State, write bool) bool {
+	switch state {
+	case ring.StateActive:
+		return true
+	case ring.StateJoining:
+		return write
+	case ring.StateLeaving:
+		return !write
+	default:
+		return false
+	}
+}
+
+/*
+
+Write fans out the operation to replicas of the key. It returns nil once
+W replicas have succeeded, otherwise aggregated *Error. The operation on
+handoff node is able to record hint of the write (see ring.Hash.Hint).
+The executor does not wait for remaining replicas after the quorum is met.
+*/
+func (e *Executor) Write(ctx context.Context, key string, op func(context.Context, ring.Node) error) error {
+	_, err := fanout(ctx, "write", key, e.Targets(key, true), e.w,
+		func(ctx context.Context, node ring.Node) (struct{}, error) {
+			return struct{}{}, op(ctx, node)
+		},
+	)
+	return err
+}
+
+/*
+
+Read fans out the operation to replicas of the key. It returns responses
+collected until R replicas have succeeded, responses include failures.
+Otherwise it returns aggregated *Error.
+*/
+func Read[T any](ctx context.Context, e *Executor, key string, op func(context.Context, ring.Node) (T, error)) ([]Response[T], error) {
+	return fanout(ctx, "read", key, e.Targets(key, false), e.r, op)
+}
+
+func fanout[T any](
+	ctx context.Context,
+	kind string,
+	key string,
+	targets []ring.Node,
+	quorum int,
+	op func(context.Context, ring.Node) (T, error),
+) ([]Response[T], error) {
+	failure := &Error{Op: kind, Key: key, Required: quorum}
+	if len(targets) < quorum {
+		failure.Errors = []error{
+			fmt.Errorf("%d replicas are available", len(targets)),
+		}
+		return nil, failure
+	}
+
+	// buffered, replicas are not blocked once quorum is met
+	ch := make(chan Response[T], len(targets))
+	for _, node := range targets {
+		go func(node ring.Node) {
+			val, err := op(ctx, node)
+			ch <- Response[T]{Node: node, Value: val, Err: err}
+		}(node)
+	}
+
+	seq := make([]Response[T], 0, len(targets))
+	for len(seq) < len(targets) {
+		select {
+		case <-ctx.Done():
+			failure.Errors = append(failure.Errors, ctx.Err())
+			return seq, failure
+		case rsp := <-ch:
+			seq = append(seq, rsp)
+			if rsp.Err != nil {
+				failure.Errors = append(failure.Errors, fmt.Errorf("%s: %w", rsp.Node.Node(), rsp.Err))
+			} else {
+				failure.Success++
+			}
+		}
+
+		if failure.Success >= quorum {
+			return seq, nil
+		}
+
+		if len(failure.Errors) > len(targets)-quorum {
+			return seq, failure
+		}
+	}
+
+	return seq, failure
+}
@@ -0,0 +1,226 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package quorum_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fogfish/it"
+	"github.com/fogfish/ring"
+	"github.com/fogfish/ring/quorum"
+)
+
+func cluster(n int) *ring.Ring {
+	r := ring.New(ring.M64_Q4096_T256)
+	for i := 0; i < n; i++ {
+		r.Join(fmt.Sprintf("node-%d", i))
+	}
+	return r
+}
+
+func nodes(seq []ring.Node) []string {
+	ids := make([]string, len(seq))
+	for i, node := range seq {
+		ids[i] = node.Node()
+	}
+	return ids
+}
+
+func has(seq []string, node string) bool {
+	for _, x := range seq {
+		if x == node {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNew(t *testing.T) {
+	r := cluster(3)
+
+	for _, cfg := range [][3]int{{0, 1, 1}, {3, 0, 2}, {3, 4, 2}, {3, 2, 0}, {3, 2, 4}} {
+		_, err := quorum.New(quorum.Static(r), cfg[0], cfg[1], cfg[2])
+		it.Ok(t).IfNotNil(err)
+	}
+
+	_, err := quorum.New(quorum.Static(r), 3, 2, 2)
+	it.Ok(t).IfNil(err)
+}
+
+func TestTargets(t *testing.T) {
+	r := cluster(8)
+	q, _ := quorum.New(quorum.Static(r), 3, 2, 2)
+
+	key := "key"
+	primary, _ := r.SuccessorOf(3, key)
+	handoff, leaving := primary[0].Node(), primary[1].Node()
+
+	it.Ok(t).
+		If(nodes(q.Targets(key, true))).Equal(nodes(q.Targets(key, false))).
+		If(len(q.Targets(key, true))).Equal(3)
+
+	r.Handoff(handoff).Drain(leaving)
+
+	write := nodes(q.Targets(key, true))
+	read := nodes(q.Targets(key, false))
+	it.Ok(t).
+		If(len(write)).Equal(3).
+		If(len(read)).Equal(3).
+		IfFalse(has(write, handoff)).
+		IfFalse(has(read, handoff)).
+		IfFalse(has(write, leaving)).
+		IfTrue(has(read, leaving)).
+		IfTrue(has(write, primary[2].Node())).
+		IfTrue(has(read, primary[2].Node()))
+
+	for _, node := range q.Targets(key, true) {
+		if hash := node.(ring.Hash); hash.Hint() != "" {
+			it.Ok(t).If(hash.Hint()).Equal(handoff)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := cluster(8)
+	q, _ := quorum.New(quorum.Static(r), 3, 2, 2)
+
+	key := "key"
+	primary, _ := r.SuccessorOf(3, key)
+
+	failOn := func(failed ...string) func(context.Context, ring.Node) error {
+		return func(ctx context.Context, node ring.Node) error {
+			if has(failed, node.Node()) {
+				return &replicaError{node.Node()}
+			}
+			return nil
+		}
+	}
+
+	it.Ok(t).
+		IfNil(q.Write(context.Background(), key, failOn())).
+		IfNil(q.Write(context.Background(), key, failOn(primary[0].Node())))
+
+	err := q.Write(context.Background(), key, failOn(primary[0].Node(), primary[2].Node()))
+	var e *quorum.Error
+	it.Ok(t).
+		IfTrue(errors.As(err, &e)).
+		If(e.Op).Equal("write").
+		If(e.Required).Equal(2).
+		IfTrue(e.Success <= 1).
+		If(len(e.Errors)).Equal(2)
+
+	var re *replicaError
+	it.Ok(t).
+		IfTrue(errors.As(err, &re)).
+		IfTrue(re.node == primary[0].Node() || re.node == primary[2].Node()).
+		IfTrue(errors.Is(err, &replicaError{primary[2].Node()})).
+		IfFalse(errors.Is(err, &replicaError{primary[1].Node()}))
+}
+
+type replicaError struct{ node string }
+
+func (e *replicaError) Error() string { return e.node + " is unavailable" }
+
+func (e *replicaError) Is(target error) bool {
+	x, ok := target.(*replicaError)
+	return ok && x.node == e.node
+}
+
+func TestRead(t *testing.T) {
+	r := cluster(8)
+	q, _ := quorum.New(quorum.Static(r), 3, 2, 2)
+
+	seq, err := quorum.Read(context.Background(), q, "key",
+		func(ctx context.Context, node ring.Node) (string, error) {
+			return node.Node(), nil
+		},
+	)
+	it.Ok(t).
+		IfNil(err).
+		If(len(seq)).Equal(2).
+		If(seq[0].Value).Equal(seq[0].Node.Node())
+}
+
+func TestReadDeadline(t *testing.T) {
+	r := cluster(8)
+	q, _ := quorum.New(quorum.Static(r), 3, 2, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	calls := int32(0)
+	seq, err := quorum.Read(ctx, q, "key",
+		func(ctx context.Context, node ring.Node) (int, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return 1, nil
+			}
+			<-ctx.Done()
+			return 0, ctx.Err()
+		},
+	)
+
+	it.Ok(t).
+		If(len(seq)).Equal(1).
+		IfTrue(errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestUnavailable(t *testing.T) {
+	r := cluster(2)
+	q, _ := quorum.New(quorum.Static(r), 3, 2, 3)
+
+	err := q.Write(context.Background(), "key",
+		func(ctx context.Context, node ring.Node) error { return nil },
+	)
+	it.Ok(t).IfNotNil(err)
+}
+
+func TestTargetsConcurrent(t *testing.T) {
+	c := ring.NewConcurrent(ring.M64_Q4096_T256)
+	for i := 0; i < 8; i++ {
+		c.Join(fmt.Sprintf("node-%d", i))
+	}
+	q, _ := quorum.New(c, 3, 2, 2)
+
+	primary, _ := c.SuccessorOf(3, "key")
+	node := primary[1].Node()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			c.Drain(node).Recover(node).Activate(node).Handoff(node).Recover(node)
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			it.Ok(t).
+				If(len(q.Targets("key", true))).Equal(3).
+				If(len(q.Targets("key", false))).Equal(3)
+		}
+	}
+}
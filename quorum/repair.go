@@ -0,0 +1,264 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package quorum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fogfish/ring"
+)
+
+// Ordering of versions
+type Ordering int
+
+// Orderings of versions
+const (
+	Before     Ordering = -1 // version happened before the other
+	Equal      Ordering = 0  // versions are equal
+	After      Ordering = 1  // version happened after the other
+	Concurrent Ordering = 2  // versions are concurrent, there is no causal order
+)
+
+func (o Ordering) String() string {
+	switch o {
+	case Before:
+		return "before"
+	case Equal:
+		return "equal"
+	case After:
+		return "after"
+	case Concurrent:
+		return "concurrent"
+	default:
+		return "unknown"
+	}
+}
+
+// Comparator orders versions of replicas
+type Comparator[V any] interface {
+	Compare(a, b V) Ordering
+}
+
+// CompareFunc is the function that implements Comparator
+type CompareFunc[V any] func(a, b V) Ordering
+
+// Compare versions
+func (f CompareFunc[V]) Compare(a, b V) Ordering { return f(a, b) }
+
+// ByTimestamp orders versions by timestamp, the last write wins
+func ByTimestamp[V any](ts func(V) time.Time) Comparator[V] {
+	return CompareFunc[V](func(a, b V) Ordering {
+		x, y := ts(a), ts(b)
+		switch {
+		case x.Before(y):
+			return Before
+		case x.After(y):
+			return After
+		default:
+			return Equal
+		}
+	})
+}
+
+// VectorClock is the version vector, the counter of updates per node
+type VectorClock map[string]uint64
+
+// Compare vector clocks
+func (vc VectorClock) Compare(other VectorClock) Ordering {
+	before, after := false, false
+
+	for node, x := range vc {
+		y := other[node]
+		before = before || x < y
+		after = after || x > y
+	}
+
+	for node, y := range other {
+		if _, exists := vc[node]; !exists && y > 0 {
+			before = true
+		}
+	}
+
+	switch {
+	case before && after:
+		return Concurrent
+	case before:
+		return Before
+	case after:
+		return After
+	default:
+		return Equal
+	}
+}
+
+// ByVectorClock orders versions by vector clocks
+func ByVectorClock[V any](clock func(V) VectorClock) Comparator[V] {
+	return CompareFunc[V](func(a, b V) Ordering {
+		return clock(a).Compare(clock(b))
+	})
+}
+
+// ErrConflict reports concurrent versions that are not merged
+var ErrConflict = errors.New("quorum: concurrent versions")
+
+// Reconciliation of replicas versions
+type Reconciliation[V any] struct {
+	Winner   V           // the latest version
+	Siblings []V         // concurrent latest versions, if they are not merged
+	Stale    []ring.Node // replicas that have outdated version
+}
+
+// Conflict is true if concurrent versions are not merged
+func (r Reconciliation[V]) Conflict() bool { return len(r.Siblings) > 1 }
+
+/*
+
+ReadRepair detects stale replicas from responses of quorum read and
+repairs them using the caller-supplied write function.
+*/
+type ReadRepair[V any] struct {
+	compare Comparator[V]
+	merge   func([]V) V
+	write   func(context.Context, ring.Node, V) error
+}
+
+// RepairOption of read repair
+type RepairOption[V any] func(*ReadRepair[V])
+
+// WithMerge defines the function to merge concurrent versions into the winner
+func WithMerge[V any](merge func([]V) V) RepairOption[V] {
+	return func(rr *ReadRepair[V]) {
+		rr.merge = merge
+	}
+}
+
+// NewReadRepair creates read repair of replicas
+func NewReadRepair[V any](
+	compare Comparator[V],
+	write func(context.Context, ring.Node, V) error,
+	opts ...RepairOption[V],
+) *ReadRepair[V] {
+	rr := &ReadRepair[V]{compare: compare, write: write}
+	for _, opt := range opts {
+		opt(rr)
+	}
+	return rr
+}
+
+/*
+
+Reconcile determines the winner and stale replicas. Failed responses are
+ignored. Concurrent latest versions are merged if the merge function is
+defined, all replicas that do not have the merged version are stale.
+Otherwise the reconciliation is the conflict, no replicas are stale.
+*/
+func (rr *ReadRepair[V]) Reconcile(seq []Response[V]) Reconciliation[V] {
+	var rec Reconciliation[V]
+
+	// distinct versions that are not preceded by any other
+	for _, rsp := range seq {
+		if rsp.Err != nil {
+			continue
+		}
+
+		latest := true
+		for i := 0; i < len(rec.Siblings); i++ {
+			switch rr.compare.Compare(rsp.Value, rec.Siblings[i]) {
+			case Before, Equal:
+				latest = false
+			case After:
+				rec.Siblings = append(rec.Siblings[:i], rec.Siblings[i+1:]...)
+				i--
+			}
+		}
+
+		if latest {
+			rec.Siblings = append(rec.Siblings, rsp.Value)
+		}
+	}
+
+	switch {
+	case len(rec.Siblings) == 0:
+		return rec
+	case len(rec.Siblings) == 1:
+		rec.Winner = rec.Siblings[0]
+	case rr.merge != nil:
+		rec.Winner = rr.merge(rec.Siblings)
+	default:
+		rec.Winner = rec.Siblings[0]
+		return rec
+	}
+	rec.Siblings = nil
+
+	for _, rsp := range seq {
+		if rsp.Err == nil && rr.compare.Compare(rsp.Value, rec.Winner) != Equal {
+			rec.Stale = append(rec.Stale, rsp.Node)
+		}
+	}
+
+	return rec
+}
+
+/*
+
+Repair reconciles responses and writes the winner to stale replicas
+concurrently. It returns ErrConflict if concurrent versions are not merged,
+failures of repair writes are returned as aggregated *Error.
+*/
+func (rr *ReadRepair[V]) Repair(ctx context.Context, key string, seq []Response[V]) (Reconciliation[V], error) {
+	rec := rr.Reconcile(seq)
+	if rec.Conflict() {
+		return rec, ErrConflict
+	}
+
+	if len(rec.Stale) == 0 {
+		return rec, nil
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	failure := &Error{Op: "repair", Key: key, Required: len(rec.Stale)}
+
+	for _, node := range rec.Stale {
+		wg.Add(1)
+		go func(node ring.Node) {
+			defer wg.Done()
+			err := rr.write(ctx, node, rec.Winner)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				failure.Errors = append(failure.Errors, fmt.Errorf("%s: %w", node.Node(), err))
+			} else {
+				failure.Success++
+			}
+		}(node)
+	}
+	wg.Wait()
+
+	if len(failure.Errors) != 0 {
+		return rec, failure
+	}
+
+	return rec, nil
+}
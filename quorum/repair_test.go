@@ -0,0 +1,145 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package quorum_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fogfish/it"
+	"github.com/fogfish/ring"
+	"github.com/fogfish/ring/quorum"
+)
+
+type version struct {
+	value string
+	ts    time.Time
+	clock quorum.VectorClock
+}
+
+func responses(r *ring.Ring, key string, seq ...version) []quorum.Response[version] {
+	primary, _ := r.SuccessorOf(uint64(len(seq)), key)
+	rsp := make([]quorum.Response[version], len(seq))
+	for i, v := range seq {
+		rsp[i] = quorum.Response[version]{Node: primary[i], Value: v}
+	}
+	return rsp
+}
+
+func TestVectorClock(t *testing.T) {
+	a := quorum.VectorClock{"a": 1}
+	b := quorum.VectorClock{"a": 1, "b": 1}
+	c := quorum.VectorClock{"a": 2}
+
+	it.Ok(t).
+		If(a.Compare(a)).Equal(quorum.Equal).
+		If(a.Compare(b)).Equal(quorum.Before).
+		If(b.Compare(a)).Equal(quorum.After).
+		If(b.Compare(c)).Equal(quorum.Concurrent).
+		If(a.Compare(quorum.VectorClock{"a": 1, "b": 0})).Equal(quorum.Equal)
+}
+
+func TestReadRepairTimestamp(t *testing.T) {
+	r := cluster(8)
+	now := time.Now()
+
+	var mutex sync.Mutex
+	repaired := map[string]string{}
+	rr := quorum.NewReadRepair(
+		quorum.ByTimestamp(func(v version) time.Time { return v.ts }),
+		func(ctx context.Context, node ring.Node, v version) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			repaired[node.Node()] = v.value
+			return nil
+		},
+	)
+
+	seq := responses(r, "key",
+		version{value: "a", ts: now.Add(-time.Second)},
+		version{value: "b", ts: now},
+		version{value: "a", ts: now.Add(-time.Second)},
+	)
+	seq = append(seq, quorum.Response[version]{Node: seq[0].Node, Err: errors.New("timeout")})
+
+	rec, err := rr.Repair(context.Background(), "key", seq)
+	it.Ok(t).
+		IfNil(err).
+		IfFalse(rec.Conflict()).
+		If(rec.Winner.value).Equal("b").
+		If(len(rec.Stale)).Equal(2).
+		If(repaired).Equal(map[string]string{
+		seq[0].Node.Node(): "b",
+		seq[2].Node.Node(): "b",
+	})
+}
+
+func TestReadRepairVectorClock(t *testing.T) {
+	r := cluster(8)
+	clock := quorum.ByVectorClock(func(v version) quorum.VectorClock { return v.clock })
+	write := func(ctx context.Context, node ring.Node, v version) error {
+		return errors.New("unavailable")
+	}
+
+	seq := responses(r, "key",
+		version{value: "a", clock: quorum.VectorClock{"a": 1}},
+		version{value: "b", clock: quorum.VectorClock{"a": 1, "b": 1}},
+		version{value: "c", clock: quorum.VectorClock{"a": 2}},
+	)
+
+	rec, err := quorum.NewReadRepair(clock, write).Repair(context.Background(), "key", seq)
+	it.Ok(t).
+		IfTrue(errors.Is(err, quorum.ErrConflict)).
+		IfTrue(rec.Conflict()).
+		If(len(rec.Siblings)).Equal(2).
+		If(len(rec.Stale)).Equal(0)
+
+	merge := quorum.WithMerge(func(seq []version) version {
+		v := version{clock: quorum.VectorClock{}}
+		values := []string{}
+		for _, x := range seq {
+			values = append(values, x.value)
+			for node, n := range x.clock {
+				if n > v.clock[node] {
+					v.clock[node] = n
+				}
+			}
+		}
+		sort.Strings(values)
+		for _, x := range values {
+			v.value += x
+		}
+		return v
+	})
+
+	rec, err = quorum.NewReadRepair(clock, write, merge).Repair(context.Background(), "key", seq)
+	var e *quorum.Error
+	it.Ok(t).
+		IfTrue(errors.As(err, &e)).
+		If(e.Op).Equal("repair").
+		If(len(e.Errors)).Equal(3).
+		IfFalse(rec.Conflict()).
+		If(rec.Winner.value).Equal("bc").
+		If(rec.Winner.clock).Equal(quorum.VectorClock{"a": 2, "b": 1}).
+		If(len(rec.Stale)).Equal(3)
+}
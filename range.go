@@ -0,0 +1,97 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import "fmt"
+
+// Range of addresses [Lo, Hi] on the ring
+type Range struct {
+	Lo uint64 // lowest address of the range
+	Hi uint64 // highest address of the range
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("[%x, %x]", r.Lo, r.Hi)
+}
+
+// Contains returns true if address belongs to the range
+func (r Range) Contains(addr uint64) bool {
+	return r.Lo <= addr && addr <= r.Hi
+}
+
+/*
+
+ShardOf returns index of the shard hit by the key
+*/
+func (ring *Ring) ShardOf(key string) int {
+	shard, _ := ring.address(key)
+	return shard
+}
+
+/*
+
+ShardOfAddress returns index of the shard that owns the address
+*/
+func (ring *Ring) ShardOfAddress(addr uint64) int {
+	return ring.shardOf(addr)
+}
+
+/*
+
+Shard returns the shard by its index, the index is 0 ≤ i < Q
+*/
+func (ring *Ring) Shard(i int) Node {
+	return ring.shards[i]
+}
+
+/*
+
+Range returns the address range [lo, hi] covered by the shard
+*/
+func (ring *Ring) Range(i int) (lo, hi uint64) {
+	return ring.span(i)
+}
+
+/*
+
+RangesOf returns address ranges owned by the node as one of N successors
+(primary or handoff) of shards. Ranges of adjacent shards are merged, ranges
+are ordered by address and do not wrap around the ring. Use N = 1 to get
+ranges coordinated by the node.
+*/
+func (ring *Ring) RangesOf(n uint64, node string) []Range {
+	seq := make([]Range, 0)
+	primary, handoff := make(Primary, 0, n), make(Handoff, 0, n)
+
+	for shard := 0; shard < int(ring.q); shard++ {
+		primary, handoff = ring.appendSuccessorOf(primary[:0], handoff[:0], n, shard)
+		if !Hashes(primary).contains(node) && !Hashes(handoff).contains(node) {
+			continue
+		}
+
+		lo, hi := ring.span(shard)
+		if last := len(seq) - 1; last >= 0 && seq[last].Hi+1 == lo {
+			seq[last].Hi = hi
+			continue
+		}
+		seq = append(seq, Range{Lo: lo, Hi: hi})
+	}
+
+	return seq
+}
@@ -0,0 +1,90 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestShardOf(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	key := randKey()
+	shard := r.ShardOf(key)
+	lo, hi := r.Range(shard)
+
+	it.Ok(t).
+		If(r.Shard(shard)).Equal(r.LookupKey(key)).
+		If(r.Shard(shard).Hash()).Equal(hi).
+		If(r.ShardOfAddress(r.Address(key))).Equal(shard).
+		If(r.ShardOfAddress(lo)).Equal(shard).
+		If(r.ShardOfAddress(hi)).Equal(shard).
+		IfTrue(Range{Lo: lo, Hi: hi}.Contains(r.Address(key)))
+
+	lo, _ = r.Range(0)
+	_, hi = r.Range(4095)
+	it.Ok(t).
+		If(lo).Equal(uint64(0)).
+		If(hi).Equal(r.highest())
+}
+
+func TestRangesOf(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	for _, n := range []uint64{1, 3} {
+		covered := 0
+		for _, node := range r.Members() {
+			ranges := r.RangesOf(n, node)
+			for i, x := range ranges {
+				it.Ok(t).IfTrue(x.Lo <= x.Hi)
+				if i > 0 {
+					it.Ok(t).IfTrue(ranges[i-1].Hi+1 < x.Lo)
+				}
+			}
+
+			for _, key := range randKeys(100) {
+				primary, _ := r.SuccessorOf(n, key)
+				owner := Hashes(primary).contains(node)
+				inRange := false
+				for _, x := range ranges {
+					inRange = inRange || x.Contains(r.Address(key))
+				}
+				it.Ok(t).If(inRange).Equal(owner)
+			}
+
+			for shard := 0; shard < int(r.q); shard++ {
+				lo, _ := r.Range(shard)
+				for _, x := range ranges {
+					if x.Contains(lo) {
+						covered++
+					}
+				}
+			}
+		}
+		it.Ok(t).If(covered).Equal(int(n) * int(r.q))
+	}
+}
@@ -20,7 +20,10 @@ package ring
 import (
 	"fmt"
 	"hash"
+	"math"
+	"math/bits"
 	"strings"
+	"sync"
 )
 
 /*
@@ -29,19 +32,75 @@ Ring is consistent hashing data type.
 */
 type Ring struct {
 	// configuration
-	m      uint64           // hash space 2^m - 1
-	q      uint64           // number of shards on the ring
-	t      uint64           // number of tokens to be claimed by node
-	hasher func() hash.Hash // hashing algorithms
+	m        uint64                  // hash space 2^m - 1
+	q        uint64                  // number of shards on the ring
+	t        uint64                  // number of tokens to be claimed by node
+	hasher   func() hash.Hash        // hashing algorithms
+	zoned    bool                    // zone aware placement of replicas
+	epsilon  float64                 // bounded load factor, 0 disables bounded loads
+	hashtag  bool                    // only hash tag {...} of key is hashed
+	extract  func(key string) string // extracts the hashed part of key
+	replicas []uint64                // replication factors of indexed preference lists
 
 	// internal state
-	arc    uint64
-	hashes Hashes
-	nodes  map[string]bool
+	arc     uint64
+	hashes  Hashes
+	shards  []Node // shards boxed to interface, lookups do not allocate memory
+	nodes   map[string]member
+	claims  []claims     // tokens claimed by nodes at each shard, built lazily
+	prefs   *preferences // preference lists of replication factors, built lazily
+	digests *sync.Pool   // hashing algorithms reused by lookups
+
+	// subscribers to topology events, shared by copies of the ring
+	observers *observers
+	pending   *[]Event
+}
+
+// member of the ring
+type member struct {
+	state  State   // lifecycle state of the member
+	weight float64 // relative capacity of the member
+	zone   string  // failure domain of the member
+	meta   *Member // descriptor of the member, it is immutable
+}
+
+// creates the member from its descriptor
+func newMember(state State, weight float64, node Member) member {
+	meta := node
+	meta.Weight = weight
+	if node.Tags != nil {
+		meta.Tags = make(map[string]string, len(node.Tags))
+		for k, v := range node.Tags {
+			meta.Tags[k] = v
+		}
+	}
+
+	return member{state: state, weight: weight, zone: node.Zone, meta: &meta}
 }
 
-// New creates instances of the ring
+// Member describes the node joining the ring
+type Member struct {
+	ID     string            `json:"id"`               // identity of the node
+	Weight float64           `json:"weight,omitempty"` // relative capacity of the node, default 1.0
+	Zone   string            `json:"zone,omitempty"`   // failure domain (e.g. availability zone, rack) of the node
+	Addr   string            `json:"addr,omitempty"`   // network address of the node (e.g. host:port)
+	Tags   map[string]string `json:"tags,omitempty"`   // arbitrary attributes of the node (e.g. version)
+}
+
+// New creates instances of the ring, it panics if configuration is invalid.
+// See NewE for error-returning variant.
 func New(opts ...Option) *Ring {
+	ring, err := NewE(opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return ring
+}
+
+// NewE creates instances of the ring, it returns ConfigError
+// if configuration is invalid
+func NewE(opts ...Option) (*Ring, error) {
 	ring := &Ring{}
 
 	M64_Q8_T8(ring)
@@ -49,22 +108,54 @@ func New(opts ...Option) *Ring {
 		opt(ring)
 	}
 
+	if err := ring.validate(); err != nil {
+		return nil, err
+	}
+
 	//
 	ring.arc = ring.segment()
+	ring.digests = newDigests(ring.hasher)
 
 	//
 	ring.empty()
+	ring.prefer()
+	ring.observers = &observers{}
 
-	return ring
+	return ring, nil
 }
 
 func (ring *Ring) empty() {
-	ring.nodes = map[string]bool{}
+	ring.nodes = map[string]member{}
 	ring.hashes = make(Hashes, ring.q)
+	ring.claims = make([]claims, ring.q)
 
 	for i, addr := range ring.addresses() {
 		ring.hashes[i] = Hash{hash: addr, rank: -1}
 	}
+	ring.box()
+}
+
+// commit the topology change
+func (ring *Ring) commit() {
+	ring.box()
+	ring.prefer()
+}
+
+// box shards to Node interface, shards are attached to descriptors of
+// members, only changed shards are boxed again
+func (ring *Ring) box() {
+	if len(ring.shards) != len(ring.hashes) {
+		ring.shards = make([]Node, len(ring.hashes))
+	}
+
+	for i := range ring.hashes {
+		ring.hashes[i].meta = ring.nodes[ring.hashes[i].node].meta
+
+		hash := ring.hashes[i]
+		if x, ok := ring.shards[i].(Hash); !ok || x != hash {
+			ring.shards[i] = hash
+		}
+	}
 }
 
 //------------------------------------------------------------------------------
@@ -75,14 +166,23 @@ func (ring *Ring) empty() {
 
 // calculate highest address of the ring
 func (ring *Ring) highest() uint64 {
-	return uint64(1<<ring.m - 1)
+	return uint64(math.MaxUint64) >> (64 - ring.m)
 }
 
-// calculate cardinality of rings shard
+// calculate cardinality of rings shard, it is 0 if the single shard
+// covers entire 64-bit address space (2^64 overflows)
 func (ring *Ring) segment() uint64 {
 	return ring.highest()/ring.q + 1
 }
 
+// calculate shard of the address
+func (ring *Ring) shardOf(addr uint64) int {
+	if ring.arc == 0 {
+		return 0
+	}
+	return int((addr / ring.arc) % ring.q)
+}
+
 // calculate entire address space for the ring
 func (ring *Ring) addresses() []uint64 {
 	seq := make([]uint64, ring.q)
@@ -92,31 +192,89 @@ func (ring *Ring) addresses() []uint64 {
 	return seq
 }
 
-// calculate address on the ring for shard
+// calculate address on the ring for shard,
+// the address of last shard is clamped to the highest address
 func (ring *Ring) addressShard(shard uint64) uint64 {
-	return (shard * ring.arc) - 1
+	if ring.arc == 0 {
+		return ring.highest()
+	}
+
+	lo := (shard - 1) * ring.arc
+	if ring.highest()-lo < ring.arc-1 {
+		return ring.highest()
+	}
+	return lo + ring.arc - 1
+}
+
+// calculate address range covered by the shard
+func (ring *Ring) span(shard int) (uint64, uint64) {
+	return uint64(shard) * ring.arc, ring.addressShard(uint64(shard) + 1)
 }
 
 // calculate address on the ring for hash
 func (ring *Ring) addressHash(hash []byte) (int, uint64) {
-	addr := uint64(hash[0])
-	for i := uint64(1); i < uint64(ring.m/8); i++ {
+	// first 8 bytes of hash are read as little-endian and masked to m bits
+	addr := uint64(0)
+	for i := 0; i < len(hash) && i < 8; i++ {
 		addr = addr | uint64(hash[i])<<(8*i)
 	}
+	addr = addr & ring.highest()
 
-	shard := (addr / ring.arc) % ring.q
-	return int(shard), addr
+	return ring.shardOf(addr), addr
 }
 
 // calculate address on the ring for key
 // it returns shard id and address of the key
 func (ring *Ring) address(key string) (int, uint64) {
-	return ring.addressHash(ring.hash(key, nil))
+	d := ring.digest()
+	defer ring.digests.Put(d)
+
+	switch {
+	case ring.hashtag:
+		key = hashTag(key)
+	case ring.extract != nil:
+		key = ring.extract(key)
+	}
+
+	d.WriteString(key)
+	return d.address(ring)
+}
+
+// digest is the hashing algorithm with buffer reused across lookups
+type digest struct {
+	hash.Hash
+	buf []byte
+}
+
+// get the digest from pool
+func (ring *Ring) digest() *digest {
+	d := ring.digests.Get().(*digest)
+	d.Reset()
+	return d
+}
+
+// WriteString writes string to hashing algorithm without memory allocation
+func (d *digest) WriteString(s string) (int, error) {
+	d.buf = append(d.buf[:0], s...)
+	return d.Write(d.buf)
+}
+
+// calculate address on the ring for written data
+func (d *digest) address(ring *Ring) (int, uint64) {
+	d.buf = d.Sum(d.buf[:0])
+	return ring.addressHash(d.buf)
 }
 
-// hash the key value
+func newDigests(hasher func() hash.Hash) *sync.Pool {
+	return &sync.Pool{
+		New: func() interface{} {
+			return &digest{Hash: hasher(), buf: make([]byte, 0, 64)}
+		},
+	}
+}
+
+// hash the key value, it is used by nodes to claim tokens
 func (ring *Ring) hash(key string, hash []byte) []byte {
-	// TODO: hashing w/o memory allocation
 	h := ring.hasher()
 	h.Write([]byte(key))
 	if hash != nil {
@@ -135,18 +293,150 @@ func (ring *Ring) hash(key string, hash []byte) []byte {
 /*
 
 Join node to the ring. Node claims Q/N shards from the ring.
+Joining the existing member activates it.
 */
 func (ring *Ring) Join(node string) *Ring {
 	if _, exists := ring.nodes[node]; exists {
-		ring.nodes[node] = true
-		return ring
+		return ring.Activate(node)
+	}
+
+	return ring.JoinWithWeight(node, 1.0)
+}
+
+/*
+
+JoinWithWeight joins node to the ring. The node claims number of tokens
+proportional to its weight (capacity) so that node with weight 4.0 gets
+about 4x more shards than the node with weight 1.0. The weight is positive
+and finite, the node claims at most about Q·log₂Q tokens. The existing member
+is re-weighted, its descriptor is kept.
+*/
+func (ring *Ring) JoinWithWeight(node string, weight float64) *Ring {
+	if !validWeight(weight) {
+		panic(fmt.Errorf("ring: invalid weight %v of node %s", weight, node))
+	}
+
+	desc := Member{ID: node}
+	if m, exists := ring.nodes[node]; exists {
+		desc = *m.meta
+	}
+	desc.Weight = weight
+
+	return ring.JoinMember(desc)
+}
+
+/*
+
+JoinMember joins node to the ring using its descriptor.
+The node claims tokens proportional to its weight. The zone is used
+by zone aware placement of replicas. The descriptor is returned with
+shards of the node (see Node.Member). Joining the existing member updates
+its descriptor and weight, zero weight keeps the current one. The state of
+the existing member is kept, use Activate or Recover to change it.
+*/
+func (ring *Ring) JoinMember(node Member) *Ring {
+	weight := node.Weight
+	if weight == 0 {
+		weight = 1.0
+		if m, exists := ring.nodes[node.ID]; exists {
+			weight = m.weight
+		}
+	}
+
+	if !validWeight(weight) {
+		panic(fmt.Errorf("ring: invalid weight %v of node %s", weight, node.ID))
+	}
+
+	defer ring.observe(EventJoin, node.ID)()
+	ring.join(node, weight)
+
+	return ring
+}
+
+// joins the node, the existing member keeps its state,
+// it is changed by state transitions only (e.g. Activate, Recover)
+func (ring *Ring) join(node Member, weight float64) {
+	defer ring.commit()
+
+	state := StateActive
+	if m, exists := ring.nodes[node.ID]; exists {
+		state = m.state
+		if m.weight == weight {
+			ring.nodes[node.ID] = newMember(state, weight, node)
+			return
+		}
+		ring.leave(node.ID)
+	}
+
+	ring.claim(node.ID, weight)
+	ring.nodes[node.ID] = newMember(state, weight, node)
+}
+
+// number of tokens claimed by the node of given weight
+func (ring *Ring) tokens(weight float64) int {
+	// Note: tokens are capped at about Q·log₂Q, the node claims token at
+	//       practically every shard (coupon collector), more tokens are useless
+	limit := ring.q * uint64(bits.Len64(ring.q))
+	t := math.Round(float64(ring.t) * weight)
+	switch {
+	case t < 1:
+		return 1
+	case t > float64(limit):
+		return int(limit)
+	default:
+		return int(t)
+	}
+}
+
+// weight of the node is positive and finite
+func validWeight(weight float64) bool {
+	return weight > 0 && !math.IsInf(weight, 1)
+}
+
+// calculate tokens of the node, the rank of token is scaled by the weight
+// so that heavy node competes with more tokens at each rank
+func (ring *Ring) tokensOf(node string, weight float64) claims {
+	if !(weight > 0) {
+		return nil
 	}
 
 	var hash []byte
 
-	for rank := 0; rank < int(ring.t); rank++ {
+	seq := make(claims, ring.tokens(weight))
+	for token := range seq {
 		hash = ring.hash(node, hash)
 		shard, addr := ring.addressHash(hash)
+		seq[token] = claim{
+			shard: shard,
+			addr:  addr,
+			rank:  int(float64(token) / weight),
+			node:  node,
+		}
+	}
+
+	return seq
+}
+
+// index tokens claimed by members of the ring
+func (ring *Ring) reindex() {
+	ring.claims = make([]claims, ring.q)
+	for node, m := range ring.nodes {
+		for _, c := range ring.tokensOf(node, m.weight) {
+			ring.claims[c.shard] = append(ring.claims[c.shard], c)
+		}
+	}
+}
+
+// claim shards for the node
+func (ring *Ring) claim(node string, weight float64) {
+	if ring.claims == nil {
+		ring.reindex()
+	}
+
+	for _, c := range ring.tokensOf(node, weight) {
+		shard, addr, rank := c.shard, c.addr, c.rank
+
+		ring.claims[shard] = append(ring.claims[shard], c)
 
 		main := ring.hashes[shard]
 
@@ -170,9 +460,6 @@ func (ring *Ring) Join(node string) *Ring {
 	}
 
 	ring.repair()
-	ring.nodes[node] = true
-
-	return ring
 }
 
 // repair unallocated shards
@@ -195,110 +482,218 @@ func (ring *Ring) repair() {
 
 /*
 
-Leave node from the ring
+Leave node from the ring. Shards claimed by the node are reassigned to
+remaining nodes, the allocation is identical to the ring built from scratch.
 */
 func (ring *Ring) Leave(node string) *Ring {
 	if _, exists := ring.nodes[node]; !exists {
 		return ring
 	}
 
-	nodes := ring.nodes
-	delete(nodes, node)
+	defer ring.observe(EventLeave, node)()
+	ring.leave(node)
 
-	ring.empty()
+	return ring
+}
 
-	for node := range nodes {
-		ring.Join(node)
+func (ring *Ring) leave(node string) {
+	defer ring.commit()
+
+	m := ring.nodes[node]
+
+	if ring.claims == nil {
+		ring.reindex()
 	}
 
-	return ring
+	delete(ring.nodes, node)
+	if len(ring.nodes) == 0 {
+		ring.empty()
+		return
+	}
+
+	for _, c := range ring.tokensOf(node, m.weight) {
+		seq := ring.claims[c.shard]
+		if !seq.contains(node) {
+			continue
+		}
+
+		ring.claims[c.shard] = seq.without(node)
+		ring.elect(c.shard)
+	}
+
+	ring.repair()
+}
+
+// elect the owner of shard from tokens claimed by nodes,
+// the smallest rank wins, bigger address wins on collision
+func (ring *Ring) elect(shard int) {
+	h := Hash{hash: ring.hashes[shard].hash, rank: -1}
+
+	for _, c := range ring.claims[shard] {
+		if h.rank == -1 || c.rank < h.rank || (c.rank == h.rank && c.addr > h.addr) {
+			h.addr = c.addr
+			h.rank = c.rank
+			h.node = c.node
+		}
+	}
+
+	ring.hashes[shard] = h
 }
 
 /*
 
-Handoff node's responsibility.
+Handoff node's responsibility. Unknown nodes are ignored.
 */
 func (ring *Ring) Handoff(node string) *Ring {
-	ring.nodes[node] = false
-	return ring
+	return ring.transition(node, StateHandoff)
 }
 
 /*
 
 SuccessorOf return N distinct nodes to route key.
 The list of nodes is split to primary and handoff replicas.
+Each primary node that is not active is substituted by a handoff node,
+see State for routing semantic of joining and leaving nodes.
 
 For each node it returns the address of shard hit by the key,
 the node identity, the rank of node identity and its address on the ring.
+Each handoff node carries the identity of primary node it substitutes (see Hash.Hint).
 */
 func (ring *Ring) SuccessorOf(n uint64, key string) (Primary, Handoff) {
 	shard, _ := ring.address(key)
-	coord := ring.hashes[shard]
+	return ring.successorOf(n, shard)
+}
+
+/*
+
+AppendSuccessorOf appends N distinct nodes to route key to the primary and
+handoff lists and returns the extended lists, see SuccessorOf.
+The lookup does not allocate memory if lists have enough capacity,
+lists are reused across lookups on the hot path:
 
-	last, head := ring.distinctNodes(n, shard)
+  primary, handoff = ring.AppendSuccessorOf(primary[:0], handoff[:0], 3, key)
+*/
+func (ring *Ring) AppendSuccessorOf(primary Primary, handoff Handoff, n uint64, key string) (Primary, Handoff) {
+	shard, _ := ring.address(key)
+	return ring.appendSuccessorOf(primary, handoff, n, shard)
+}
 
-	primary := ring.primaryNodes(n, coord, head)
-	if len(primary) == int(n) {
-		return Primary(primary), nil
+// returns N distinct nodes to route shard
+func (ring *Ring) successorOf(n uint64, shard int) (Primary, Handoff) {
+	return ring.appendSuccessorOf(make(Primary, 0, n), nil, n, shard)
+}
+
+// appends N distinct nodes to route shard
+func (ring *Ring) appendSuccessorOf(primary Primary, handoff Handoff, n uint64, shard int) (Primary, Handoff) {
+	if primary, handoff, ok := ring.preferred(primary, handoff, n, shard); ok {
+		return primary, handoff
 	}
 
-	hn := int(n) - len(primary)
-	handoff := make(Hashes, 0, n)
-	for i := 1; i < int(ring.q); i++ {
-		hash := ring.hashes[(last+i)%int(ring.q)]
-
-		if ring.nodes[hash.node] && !handoff.contains(hash.node) && !primary.contains(hash.node) {
-			handoff = append(handoff, Hash{
-				hash: coord.hash,
-				addr: hash.addr,
-				rank: hash.rank,
-				node: hash.node,
-			})
+	return ring.walkSuccessorOf(primary, handoff, n, shard)
+}
+
+// walks the ring to find N distinct nodes to route shard
+func (ring *Ring) walkSuccessorOf(primary Primary, handoff Handoff, n uint64, shard int) (Primary, Handoff) {
+	coord := ring.hashes[shard]
+
+	// distinct nodes are walked into spare capacity of primary list
+	last, head := ring.walk(Hashes(primary[len(primary):]), int(n), shard, nil, false)
+
+	// nodes substituted by handoff, in the order of preference.
+	// Joining and leaving nodes are still primary, they are not hinted.
+	var buf [8]string
+	standIn := buf[:0]
+
+	hn := int(n)
+	seq := head[:0]
+	for _, hash := range head {
+		state := ring.nodes[hash.node].state
+		switch {
+		case state == StateActive:
+			hn--
+		case state.isPrimary():
+			standIn = append(standIn, "")
+		default:
+			standIn = append(standIn, hash.node)
 		}
 
-		if len(handoff) == hn {
-			break
+		if state.isPrimary() {
+			hash.hash = coord.hash
+			seq = append(seq, hash)
+		}
+	}
+	primary = append(primary, seq...)
+
+	if hn == 0 {
+		return primary, handoff
+	}
+
+	_, tail := ring.walk(Hashes(handoff[len(handoff):]), hn, last+1, seq, true)
+	for i := range tail {
+		tail[i].hash = coord.hash
+		if i < len(standIn) {
+			tail[i].hint = standIn[i]
 		}
 	}
+	handoff = append(handoff, tail...)
 
-	return Primary(primary), Handoff(handoff)
+	return primary, handoff
 }
 
-// returns N distinct nodes and position on the ring
-func (ring *Ring) distinctNodes(n uint64, fromShard int) (int, Hashes) {
+// walks the ring from the shard and appends N distinct nodes to the sequence,
+// nodes from exclude list are skipped. It returns position of the last node.
+// Zone aware placement picks nodes from distinct zones first and then
+// falls back to distinct nodes.
+func (ring *Ring) walk(seq Hashes, n int, fromShard int, exclude Hashes, activeOnly bool) (int, Hashes) {
+	q := int(ring.q)
 	last := 0
-	head := make(Hashes, 0, n)
-	for i := 0; i < int(ring.q); i++ {
-		last = (fromShard + i) % int(ring.q)
-		hash := ring.hashes[last]
 
-		if !head.contains(hash.node) {
-			head = append(head, hash)
+	for pass := 0; pass < 2 && len(seq) < n; pass++ {
+		zoned := ring.zoned && pass == 0
+
+		for i := 0; i < q && len(seq) < n; i++ {
+			hash := ring.hashes[(fromShard+i)%q]
+
+			if activeOnly && ring.nodes[hash.node].state != StateActive {
+				continue
+			}
+
+			if seq.contains(hash.node) || exclude.contains(hash.node) {
+				continue
+			}
+
+			if zoned && (ring.hasZone(seq, hash.node) || ring.hasZone(exclude, hash.node)) {
+				continue
+			}
+
+			seq = append(seq, hash)
+			if i > last {
+				last = i
+			}
 		}
 
-		if len(head) == int(n) {
+		if !ring.zoned {
 			break
 		}
 	}
 
-	return last, head
+	return (fromShard + last) % q, seq
 }
 
-// build list of primary nodes from list of candidates
-func (ring *Ring) primaryNodes(n uint64, coord Hash, hashes Hashes) Hashes {
-	primary := make(Hashes, 0, n)
-	for _, hash := range hashes {
-		if ring.nodes[hash.node] {
-			primary = append(primary, Hash{
-				hash: coord.hash,
-				addr: hash.addr,
-				rank: hash.rank,
-				node: hash.node,
-			})
+// check if hashes contains a node from the same zone as the node
+func (ring *Ring) hasZone(hashes Hashes, node string) bool {
+	zone := ring.nodes[node].zone
+	if zone == "" {
+		return false
+	}
+
+	for _, x := range hashes {
+		if ring.nodes[x.node].zone == zone {
+			return true
 		}
 	}
 
-	return primary
+	return false
 }
 
 /*
@@ -306,7 +701,7 @@ func (ring *Ring) primaryNodes(n uint64, coord Hash, hashes Hashes) Hashes {
 Address calculates address of key on the ring
 */
 func (ring *Ring) Address(key string) uint64 {
-	_, addr := ring.addressHash(ring.hash(key, nil))
+	_, addr := ring.address(key)
 	return addr
 }
 
@@ -315,9 +710,7 @@ func (ring *Ring) Address(key string) uint64 {
 Lookup the address position on the ring
 */
 func (ring *Ring) Lookup(addr uint64) Node {
-	shard := (addr / ring.arc) % ring.q
-	hash := ring.hashes[shard]
-	return hash
+	return ring.shards[ring.shardOf(addr)]
 }
 
 /*
@@ -326,8 +719,7 @@ lookup the key position on the ring
 */
 func (ring *Ring) LookupKey(key string) Node {
 	shard, _ := ring.address(key)
-	hash := ring.hashes[shard]
-	return hash
+	return ring.shards[shard]
 }
 
 /*
@@ -335,9 +727,7 @@ func (ring *Ring) LookupKey(key string) Node {
 Before returns list of N predecessors shards for the address.
 */
 func (ring *Ring) Before(n uint64, addr uint64) []Node {
-	shard := (addr / ring.arc) % ring.q
-
-	return ring.predecessor(min(n, ring.q), int(shard))
+	return ring.predecessor(min(n, ring.q), ring.shardOf(addr))
 }
 
 /*
@@ -369,9 +759,7 @@ func (ring *Ring) predecessor(n uint64, shard int) []Node {
 After returns list of N successors shards for the address.
 */
 func (ring *Ring) After(n uint64, addr uint64) []Node {
-	shard := (addr / ring.arc) % ring.q
-
-	return ring.successor(min(n, ring.q), int(shard))
+	return ring.successor(min(n, ring.q), ring.shardOf(addr))
 }
 
 /*
@@ -416,6 +804,20 @@ func (ring *Ring) Has(node string) bool {
 
 /*
 
+Member returns descriptor of the node registered at ring.
+Tags of the descriptor are shared, they must not be modified.
+*/
+func (ring *Ring) Member(node string) (Member, bool) {
+	m, exists := ring.nodes[node]
+	if !exists {
+		return Member{}, false
+	}
+
+	return *m.meta, true
+}
+
+/*
+
 Members return list of nodes registered at ring
 */
 func (ring *Ring) Members() []string {
@@ -448,11 +850,8 @@ func (ring *Ring) Nodes() map[string][]Node {
 Shards returns ring topology and its allocation
 */
 func (ring *Ring) Shards() []Node {
-	hashes := make([]Node, len(ring.hashes))
-
-	for i, hash := range ring.hashes {
-		hashes[i] = hash
-	}
+	hashes := make([]Node, len(ring.shards))
+	copy(hashes, ring.shards)
 
 	return hashes
 }
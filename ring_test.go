@@ -19,8 +19,12 @@
 package ring
 
 import (
+	"crypto/sha1"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"net"
@@ -39,9 +43,9 @@ func TestRing(t *testing.T) {
 	}
 
 	q := uint64(8)
-	for _, m := range []uint64{8, 16, 32, 64} {
+	for _, m := range []uint64{8, 12, 16, 32, 40, 64} {
 		t.Run(fmt.Sprintf("m.%d", m), func(t *testing.T) {
-			r := New(withM(m), WithQ(q))
+			r := New(WithM(m), WithQ(q))
 			for _, node := range nodes {
 				r.Join(node)
 			}
@@ -134,6 +138,106 @@ func TestRing(t *testing.T) {
 	}
 }
 
+func TestRingBitWidth(t *testing.T) {
+	for _, m := range []uint64{1, 7, 12, 40, 63, 64} {
+		r := New(WithM(m), WithQ(2), WithHash(sha1.New))
+		r.Join("node")
+
+		highest := uint64(math.MaxUint64) >> (64 - m)
+		shards := r.Shards()
+		it.Ok(t).
+			If(shards[0].Hash()).Equal(highest/2).
+			If(shards[1].Hash()).Equal(highest)
+
+		for _, key := range randKeys(100) {
+			it.Ok(t).IfTrue(r.Address(key) <= highest)
+		}
+
+		single := New(WithM(m), WithQ(1), WithHash(sha1.New))
+		single.Join("node")
+		it.Ok(t).
+			If(single.Shards()[0].Hash()).Equal(highest).
+			If(single.LookupKey("key").Node()).Equal("node")
+	}
+
+	// Note: last shard is truncated if q does not divide 2^m
+	r := New(WithM(12), WithQ(5))
+	shards := r.Shards()
+	it.Ok(t).
+		If(shards[0].Hash()).Equal(uint64(819)).
+		If(shards[4].Hash()).Equal(uint64(4095)).
+		If(r.Lookup(4095).Hash()).Equal(uint64(4095))
+}
+
+func TestRingInvalidConfig(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithM(0)},
+		{WithM(65)},
+		{WithQ(0)},
+		{WithM(4), WithQ(17)},
+		{WithM(4), WithQ(9)},
+	} {
+		func() {
+			defer func() { it.Ok(t).IfNotNil(recover()) }()
+			New(opts...)
+		}()
+	}
+
+	it.Ok(t).
+		If(New(WithM(4), WithQ(16)).Shards()[15].Hash()).Equal(uint64(15))
+}
+
+func TestNewE(t *testing.T) {
+	for param, opts := range map[string][]Option{
+		"m":       {WithM(65)},
+		"q":       {WithQ(0)},
+		"t":       {WithT(0)},
+		"hash":    {WithHash(nil)},
+		"epsilon": {WithBoundedLoad(-1)},
+	} {
+		r, err := NewE(opts...)
+		var e *ConfigError
+		it.Ok(t).
+			IfNil(r).
+			IfTrue(errors.As(err, &e)).
+			If(e.Param).Equal(param)
+	}
+
+	fnv32 := func() hash.Hash { return fnv.New32a() }
+	_, err := NewE(WithM64(), WithHash(fnv32))
+	it.Ok(t).IfNotNil(err)
+
+	_, err = NewE(WithM32(), WithHash(fnv32))
+	it.Ok(t).IfNil(err)
+
+	// single shard covers entire address space of m = 64
+	r, err := NewE(WithQ(1))
+	it.Ok(t).IfNil(err)
+	r.Join("a").Join("b")
+	lo, hi := r.Range(0)
+	primary, _ := r.SuccessorOf(2, "key")
+	it.Ok(t).
+		If(lo).Equal(uint64(0)).
+		If(hi).Equal(uint64(math.MaxUint64)).
+		If(r.LookupKey("key").Node()).Equal(r.Shard(0).Node()).
+		If(r.Lookup(math.MaxUint64).Node()).Equal(r.Shard(0).Node()).
+		If(len(primary)).Equal(1)
+
+	bin, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+	var x Ring
+	it.Ok(t).
+		IfNil(x.UnmarshalBinary(bin)).
+		If(x.LookupKey("key").Node()).Equal(r.LookupKey("key").Node())
+
+	js, err := r.MarshalJSON()
+	it.Ok(t).IfNil(err)
+	var y Ring
+	it.Ok(t).
+		IfNil(y.UnmarshalJSON(js)).
+		If(y.LookupKey("key").Node()).Equal(r.LookupKey("key").Node())
+}
+
 func TestAllocation(t *testing.T) {
 	for x := 1; x <= 10; x++ {
 		n := 1 << x
@@ -178,6 +282,272 @@ func TestJoin(t *testing.T) {
 	}
 }
 
+func TestLeave(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	seq := randKeys(64)
+	for i, ip := range seq {
+		r.JoinWithWeight(ip, 1.0+float64(i%3))
+	}
+
+	for _, ip := range seq[:16] {
+		r.Leave(ip)
+	}
+
+	rand.Shuffle(len(seq), func(i, j int) { seq[i], seq[j] = seq[j], seq[i] })
+	x := New(M64_Q4096_T256)
+	for _, ip := range seq {
+		if m, has := r.Member(ip); has {
+			x.JoinWithWeight(ip, m.Weight)
+		}
+	}
+
+	it.Ok(t).
+		If(r.Size()).Equal(48).
+		If(r.hashes).Equal(x.hashes)
+
+	for _, ip := range r.Members() {
+		r.Leave(ip)
+	}
+	it.Ok(t).
+		If(r.Size()).Equal(0).
+		If(r.hashes).Equal(New(M64_Q4096_T256).hashes)
+}
+
+func TestLeaveSnapshot(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	seq := randKeys(16)
+	for _, ip := range seq {
+		r.Join(ip)
+	}
+
+	data, err := r.MarshalBinary()
+	it.Ok(t).IfNil(err)
+
+	c := New()
+	it.Ok(t).IfNil(c.UnmarshalBinary(data))
+
+	r.Leave(seq[0]).Leave(seq[1])
+	c.Leave(seq[0]).Leave(seq[1])
+	it.Ok(t).If(c.hashes).Equal(r.hashes)
+}
+
+func TestJoinWithWeight(t *testing.T) {
+	q := 4096.0
+	share := func(r *Ring, node string) float64 {
+		return float64(len(r.Nodes()[node])) / q
+	}
+
+	// Note: testing a perfect allocation model
+	r := New(M64_Q4096_T256, WithT(4096))
+	nodes := randKeys(4)
+	for _, ip := range nodes {
+		r.Join(ip)
+	}
+	r.JoinWithWeight("node", 4.0)
+	it.Ok(t).IfTrue(math.Abs(share(r, "node")-4.0/8.0) < 0.05)
+
+	r.Leave(nodes[0])
+	it.Ok(t).
+		If(r.nodes["node"].weight).Equal(4.0).
+		IfTrue(math.Abs(share(r, "node")-4.0/7.0) < 0.05)
+
+	r.JoinWithWeight("node", 1.0)
+	it.Ok(t).
+		If(r.nodes["node"].weight).Equal(1.0).
+		IfTrue(math.Abs(share(r, "node")-1.0/4.0) < 0.05)
+
+	// re-weighting keeps the descriptor of member
+	r.JoinMember(Member{ID: "a", Zone: "z1", Addr: "h:1", Tags: map[string]string{"v": "1"}})
+	r.JoinWithWeight("a", 2.0)
+	m, _ := r.Member("a")
+	it.Ok(t).
+		If(m).Equal(Member{ID: "a", Weight: 2.0, Zone: "z1", Addr: "h:1", Tags: map[string]string{"v": "1"}}).
+		If(r.nodes["a"].zone).Equal("z1")
+}
+
+func TestJoinWithInvalidWeight(t *testing.T) {
+	for _, weight := range []float64{-1, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		r := New(M64_Q4096_T256)
+		func() {
+			defer func() { it.Ok(t).IfNotNil(recover()) }()
+			r.JoinWithWeight("a", weight)
+		}()
+		func() {
+			defer func() { it.Ok(t).IfNotNil(recover()) }()
+			r.JoinMember(Member{ID: "a", Weight: weight})
+		}()
+	}
+
+	// tokens of heavy node are capped by number of shards
+	r := New(M64_Q4096_T256)
+	r.Join("a").JoinWithWeight("b", 4.0).JoinWithWeight("heavy", 1e300)
+	it.Ok(t).
+		If(r.tokens(1e300)).Equal(4096 * 13).
+		IfTrue(len(r.Nodes()["heavy"]) > len(r.Nodes()["b"]))
+}
+
+func TestZoneAware(t *testing.T) {
+	zones := []string{"a", "b", "c"}
+	zoneOf := func(r *Ring, node string) string {
+		m, _ := r.Member(node)
+		return m.Zone
+	}
+
+	r := New(M64_Q4096_T256, WithZoneAware())
+	for i, ip := range randKeys(9) {
+		r.JoinMember(Member{ID: ip, Zone: zones[i%3]})
+	}
+
+	for i := 0; i < 1000; i++ {
+		primary, handoff := r.SuccessorOf(3, randKey())
+		it.Ok(t).
+			If(len(primary)).Equal(3).
+			If(len(handoff)).Equal(0)
+
+		seen := map[string]bool{}
+		for _, hash := range primary {
+			seen[zoneOf(r, hash.Node())] = true
+		}
+		it.Ok(t).If(len(seen)).Equal(3)
+	}
+
+	key := randKey()
+	primary, _ := r.SuccessorOf(3, key)
+	r.Handoff(primary[1].Node())
+
+	primary, handoff := r.SuccessorOf(3, key)
+	it.Ok(t).
+		If(len(primary)).Equal(2).
+		If(len(handoff)).Equal(1).
+		IfFalse(zoneOf(r, handoff[0].Node()) == zoneOf(r, primary[0].Node())).
+		IfFalse(zoneOf(r, handoff[0].Node()) == zoneOf(r, primary[1].Node()))
+}
+
+func TestZoneAwareFallback(t *testing.T) {
+	r := New(M64_Q4096_T256, WithZoneAware())
+	for i, ip := range randKeys(6) {
+		r.JoinMember(Member{ID: ip, Zone: []string{"a", "b"}[i%2]})
+	}
+
+	for i := 0; i < 1000; i++ {
+		primary, _ := r.SuccessorOf(3, randKey())
+		a, _ := r.Member(primary[0].Node())
+		b, _ := r.Member(primary[1].Node())
+		it.Ok(t).
+			If(len(primary)).Equal(3).
+			IfFalse(a.Zone == b.Zone).
+			IfFalse(primary[2].Node() == primary[0].Node()).
+			IfFalse(primary[2].Node() == primary[1].Node())
+	}
+}
+
+func TestMemberMetadata(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	tags := map[string]string{"version": "1.2"}
+	r.JoinMember(Member{ID: "node", Zone: "eu-west-1a", Addr: "10.0.0.1:8080", Tags: tags})
+	tags["version"] = "1.3"
+
+	expect := Member{ID: "node", Weight: 1.0, Zone: "eu-west-1a", Addr: "10.0.0.1:8080",
+		Tags: map[string]string{"version": "1.2"}}
+	m, _ := r.Member("node")
+	it.Ok(t).If(m).Equal(expect)
+
+	for _, shard := range r.Nodes()["node"] {
+		it.Ok(t).If(shard.Member()).Equal(expect)
+	}
+
+	for _, key := range randKeys(100) {
+		primary, _ := r.SuccessorOf(3, key)
+		for _, x := range primary {
+			m, _ := r.Member(x.Node())
+			it.Ok(t).If(x.Member()).Equal(m)
+		}
+
+		node := r.LookupKey(key)
+		m, _ = r.Member(node.Node())
+		it.Ok(t).If(node.Member()).Equal(m)
+	}
+
+	r.JoinMember(Member{ID: "node", Addr: "10.0.0.2:8080"})
+	it.Ok(t).If(r.Nodes()["node"][0].Member().Addr).Equal("10.0.0.2:8080")
+
+	// descriptor update keeps the weight and shards of the member
+	r.JoinMember(Member{ID: "heavy", Weight: 4})
+	shards := len(r.Nodes()["heavy"])
+	r.JoinMember(Member{ID: "heavy", Addr: "10.0.0.3:8080"})
+	m, _ = r.Member("heavy")
+	it.Ok(t).
+		If(m.Weight).Equal(4.0).
+		If(m.Addr).Equal("10.0.0.3:8080").
+		If(len(r.Nodes()["heavy"])).Equal(shards)
+
+	// descriptor update of unavailable member keeps its state
+	r.Handoff("heavy")
+	r.JoinMember(Member{ID: "heavy", Addr: "10.0.0.4:8080"})
+	m, _ = r.Member("heavy")
+	it.Ok(t).
+		If(r.State("heavy")).Equal(StateHandoff).
+		If(m.Addr).Equal("10.0.0.4:8080").
+		If(len(r.Nodes()["heavy"])).Equal(shards)
+}
+
+func TestFastHash(t *testing.T) {
+	for _, opt := range []Option{WithXXHash64(), WithFNV1a(), WithMurmur3(), WithSipHash(0, 0)} {
+		r := New(M64_Q4096_T256, opt)
+		for _, node := range randKeys(8) {
+			r.Join(node)
+		}
+
+		it.Ok(t).If(len(r.Nodes())).Equal(8)
+		for _, shards := range r.Nodes() {
+			it.Ok(t).IfTrue(len(shards) > 256)
+		}
+	}
+}
+
+func TestLookupAllocation(t *testing.T) {
+	for _, opt := range []Option{WithHash(sha1.New), WithXXHash64()} {
+		r := New(M64_Q4096_T256, opt)
+		for _, node := range randKeys(8) {
+			r.Join(node)
+		}
+		r.Handoff(r.LookupKey("key").Node())
+
+		primary, handoff := make(Primary, 0, 3), make(Handoff, 0, 3)
+		allocs := testing.AllocsPerRun(100, func() {
+			r.LookupKey("key")
+			primary, handoff = r.AppendSuccessorOf(primary[:0], handoff[:0], 3, "key")
+		})
+
+		p, h := r.SuccessorOf(3, "key")
+		it.Ok(t).
+			If(primary).Equal(p).
+			If(handoff).Equal(h).
+			If(len(handoff)).Equal(1)
+		if !raceEnabled {
+			it.Ok(t).If(allocs).Equal(0.0)
+		}
+	}
+}
+
+func TestAppendSuccessorOf(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+
+	key := randKey()
+	p, h := r.SuccessorOf(3, key)
+	primary, handoff := r.AppendSuccessorOf(Primary{Hash{}}, nil, 3, key)
+	it.Ok(t).
+		If(primary[1:]).Equal(p).
+		If(handoff).Equal(h)
+}
+
 func randKey() string {
 	buf := make([]byte, 4)
 	ip := rand.Uint32()
@@ -207,6 +577,20 @@ func BenchmarkJoin(b *testing.B) {
 	}
 }
 
+func BenchmarkLeave(b *testing.B) {
+	r := New(M64_Q4096_T256)
+	for i := 0; i < 1000; i++ {
+		r.Join(randKey())
+	}
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		node := randKey()
+		r.Join(node)
+		r.Leave(node)
+	}
+}
+
 func BenchmarkWhere(b *testing.B) {
 	r := New(M64_Q4096_T256)
 	for i := 0; i < 100; i++ {
@@ -218,6 +602,29 @@ func BenchmarkWhere(b *testing.B) {
 	}
 }
 
+func BenchmarkLookupKey(b *testing.B) {
+	for name, opt := range map[string]Option{
+		"sha1":     WithHash(sha1.New),
+		"xxhash64": WithXXHash64(),
+		"fnv1a":    WithFNV1a(),
+		"murmur3":  WithMurmur3(),
+		"siphash":  WithSipHash(0, 0),
+	} {
+		b.Run(name, func(b *testing.B) {
+			r := New(M64_Q4096_T256, opt)
+			for i := 0; i < 100; i++ {
+				r.Join(randKey())
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				r.LookupKey("key")
+			}
+		})
+	}
+}
+
 func BenchmarkSuccessors(b *testing.B) {
 	r := New(M64_Q4096_T256)
 	for i := 0; i < 100; i++ {
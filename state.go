@@ -0,0 +1,179 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import "fmt"
+
+/*
+
+State of the node at the ring. The state defines routing of the node's shards:
+
+  - Joining node owns shards and receives writes only. It is listed as primary
+    and an active handoff node substitutes it for reads.
+
+  - Active node owns shards, it is listed as primary.
+
+  - Handoff node is temporary unavailable, an active handoff node
+    substitutes it for reads and writes.
+
+  - Leaving node still owns shards and serves reads only while data is
+    streamed out. It is listed as primary and an active handoff node
+    substitutes it for writes.
+
+  - Down node is failed, an active handoff node substitutes it for reads and writes.
+*/
+type State int
+
+// States of the node
+const (
+	StateUnknown State = iota
+	StateJoining
+	StateActive
+	StateHandoff
+	StateLeaving
+	StateDown
+)
+
+var states = []string{"unknown", "joining", "active", "handoff", "leaving", "down"}
+
+func (state State) String() string {
+	if state < 0 || int(state) >= len(states) {
+		return states[StateUnknown]
+	}
+	return states[state]
+}
+
+// MarshalText encodes state as text
+func (state State) MarshalText() ([]byte, error) {
+	return []byte(state.String()), nil
+}
+
+// UnmarshalText decodes state from text
+func (state *State) UnmarshalText(text []byte) error {
+	for i, x := range states {
+		if x == string(text) {
+			*state = State(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("ring: unknown state %s", text)
+}
+
+// state is known lifecycle state of the member
+func (state State) valid() bool {
+	return state >= StateJoining && state <= StateDown
+}
+
+// node is listed as primary
+func (state State) isPrimary() bool {
+	return state == StateJoining || state == StateActive || state == StateLeaving
+}
+
+/*
+
+State returns the state of the node, StateUnknown if node is not a member.
+*/
+func (ring *Ring) State(node string) State {
+	return ring.nodes[node].state
+}
+
+// transition the node to the state and notify subscribers
+func (ring *Ring) transition(node string, state State, from ...State) *Ring {
+	if _, exists := ring.nodes[node]; !exists {
+		return ring
+	}
+
+	defer ring.observe(EventState, node)()
+	return ring.transit(node, state, from...)
+}
+
+// transition the node to the state, unknown nodes are ignored
+func (ring *Ring) transit(node string, state State, from ...State) *Ring {
+	m, exists := ring.nodes[node]
+	if !exists {
+		return ring
+	}
+
+	if len(from) != 0 && !isState(m.state, from) {
+		return ring
+	}
+
+	m.state = state
+	ring.nodes[node] = m
+	ring.prefer()
+	return ring
+}
+
+func isState(state State, seq []State) bool {
+	for _, x := range seq {
+		if x == state {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+
+Bootstrap joins node to the ring in joining state. The node claims
+its shards and receives writes but it does not serve reads until
+it is activated.
+*/
+func (ring *Ring) Bootstrap(node string) *Ring {
+	if _, exists := ring.nodes[node]; exists {
+		return ring
+	}
+
+	defer ring.observe(EventJoin, node)()
+	ring.join(Member{ID: node}, 1.0)
+	return ring.transit(node, StateJoining)
+}
+
+/*
+
+Activate the node, it serves reads and writes.
+*/
+func (ring *Ring) Activate(node string) *Ring {
+	return ring.transition(node, StateActive)
+}
+
+/*
+
+Recover node from handoff or down state.
+*/
+func (ring *Ring) Recover(node string) *Ring {
+	return ring.transition(node, StateActive, StateHandoff, StateDown)
+}
+
+/*
+
+Drain node before it leaves the ring. The node serves reads only
+while its data is streamed to the successors.
+*/
+func (ring *Ring) Drain(node string) *Ring {
+	return ring.transition(node, StateLeaving)
+}
+
+/*
+
+Fail marks node as down.
+*/
+func (ring *Ring) Fail(node string) *Ring {
+	return ring.transition(node, StateDown)
+}
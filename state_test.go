@@ -0,0 +1,154 @@
+/*
+
+  Copyright 2012 Dmitry Kolesnikov, All Rights Reserved
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ring
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fogfish/it"
+)
+
+func TestStateTransition(t *testing.T) {
+	r := New()
+	r.Join("a").Join("b").Bootstrap("c")
+
+	it.Ok(t).
+		If(r.State("a")).Equal(StateActive).
+		If(r.State("c")).Equal(StateJoining).
+		If(r.State("x")).Equal(StateUnknown)
+
+	r.Handoff("x").Fail("x")
+	it.Ok(t).
+		If(r.Size()).Equal(3).
+		IfFalse(r.Has("x"))
+
+	r.Recover("c")
+	it.Ok(t).If(r.State("c")).Equal(StateJoining)
+
+	r.Activate("c")
+	it.Ok(t).If(r.State("c")).Equal(StateActive)
+
+	r.Handoff("a").Fail("b")
+	it.Ok(t).
+		If(r.State("a")).Equal(StateHandoff).
+		If(r.State("b")).Equal(StateDown)
+
+	r.Recover("a").Recover("b")
+	it.Ok(t).
+		If(r.State("a")).Equal(StateActive).
+		If(r.State("b")).Equal(StateActive)
+
+	r.Drain("a")
+	it.Ok(t).If(r.State("a")).Equal(StateLeaving)
+
+	r.Join("a")
+	it.Ok(t).If(r.State("a")).Equal(StateActive)
+}
+
+func TestStateJoinMember(t *testing.T) {
+	r := New(M64_Q4096_T256)
+	r.Join("a").Join("b").Bootstrap("c")
+	r.Handoff("b")
+
+	events := []Event{}
+	r.Subscribe(func(e Event) { events = append(events, e) })
+
+	// descriptor and weight updates keep the state of member
+	r.JoinMember(Member{ID: "b", Addr: "10.0.0.2:8080"})
+	r.JoinMember(Member{ID: "c", Addr: "10.0.0.3:8080"})
+	it.Ok(t).
+		If(r.State("b")).Equal(StateHandoff).
+		If(r.State("c")).Equal(StateJoining).
+		If(len(events)).Equal(0)
+
+	r.JoinWithWeight("b", 2.0).JoinWithWeight("c", 2.0)
+	it.Ok(t).
+		If(r.State("b")).Equal(StateHandoff).
+		If(r.State("c")).Equal(StateJoining)
+	for _, e := range events {
+		it.Ok(t).If(e.Old).Equal(e.New)
+	}
+
+	r.Recover("b")
+	it.Ok(t).
+		If(r.State("b")).Equal(StateActive).
+		If(events[len(events)-1].Type).Equal(EventState)
+}
+
+func TestStateRouting(t *testing.T) {
+	key := "One ring to rule them all"
+	nodes := func(seq []Hash) []string {
+		ids := make([]string, len(seq))
+		for i, x := range seq {
+			ids[i] = x.Node()
+		}
+		return ids
+	}
+
+	r := New(M64_Q4096_T256)
+	for _, node := range randKeys(8) {
+		r.Join(node)
+	}
+	primary, _ := r.SuccessorOf(3, key)
+	seq := nodes(primary)
+
+	for _, state := range []State{StateJoining, StateLeaving} {
+		r.transit(seq[1], state)
+
+		p, h := r.SuccessorOf(3, key)
+		it.Ok(t).
+			If(nodes(p)).Equal(seq).
+			If(len(h)).Equal(1).
+			IfFalse(contains(seq, h[0].Node())).
+			If(r.State(h[0].Node())).Equal(StateActive)
+	}
+
+	for _, state := range []State{StateHandoff, StateDown} {
+		r.transit(seq[1], state)
+
+		p, h := r.SuccessorOf(3, key)
+		it.Ok(t).
+			If(nodes(p)).Equal([]string{seq[0], seq[2]}).
+			If(len(h)).Equal(1).
+			IfFalse(contains(seq, h[0].Node())).
+			If(r.State(h[0].Node())).Equal(StateActive)
+	}
+
+	r.Recover(seq[1])
+	p, h := r.SuccessorOf(3, key)
+	it.Ok(t).
+		If(nodes(p)).Equal(seq).
+		If(len(h)).Equal(0)
+}
+
+func TestStateText(t *testing.T) {
+	for _, state := range []State{StateUnknown, StateJoining, StateActive, StateHandoff, StateLeaving, StateDown} {
+		data, err := json.Marshal(state)
+		it.Ok(t).IfNil(err)
+
+		var x State
+		it.Ok(t).
+			IfNil(json.Unmarshal(data, &x)).
+			If(x).Equal(state)
+	}
+
+	var x State
+	it.Ok(t).IfNotNil(json.Unmarshal([]byte(`"unsupported"`), &x))
+}